@@ -1,14 +1,24 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"go-modular-boilerplate/internal/pkg/auth"
 	"go-modular-boilerplate/internal/pkg/bus"
+	simplecache "go-modular-boilerplate/internal/pkg/cache"
 	"go-modular-boilerplate/internal/pkg/config"
 	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/jwt"
 	"go-modular-boilerplate/internal/pkg/logger"
+	"go-modular-boilerplate/internal/pkg/problem"
+	"go-modular-boilerplate/internal/pkg/rbac"
 	"go-modular-boilerplate/internal/pkg/server"
 	_validator "go-modular-boilerplate/internal/pkg/validator"
-	"time"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
@@ -18,15 +28,23 @@ import (
 // App represents the application
 type App struct {
 	db      *gorm.DB
+	dbModel *database.DBModel
 	server  *server.ServerContext
 	modules []Module
+	started []Module
 	r       *echo.Echo
 	logger  *logger.Logger
+	event   *bus.EventBus
+	cache   simplecache.ICache
+	cors    *corsMiddleware
+	rbac    *rbac.RBAC
+
+	tlsEnabled bool
 }
 
 // NewApp creates a new application
 func NewApp(cfg *logger.Config) (*App, error) {
-	appLogger, err := logger.NewLogger(*cfg, config.GetString("server.app_name"))
+	appLogger, err := logger.NewLogger(*cfg, config.Get().Server.AppName)
 	if err != nil {
 		return nil, err
 	}
@@ -44,70 +62,176 @@ func (a *App) SetRouter() *echo.Echo {
 // RegisterModule registers a module with the application
 func (a *App) RegisterModule(module Module) {
 	a.modules = append(a.modules, module)
-	a.logger.Info("Registered module: %s", module.Name())
+	a.logger.Info("Registered module", "module", module.Name())
 }
 
-// Initialize initializes the application
-func (a *App) Initialize() error {
-	a.logger.Info("Initializing application...")
+// prepare orders the modules by their dependencies and opens the database.
+// It is shared by Initialize and the migrate command.
+func (a *App) prepare() error {
+	if a.db != nil {
+		return nil
+	}
+
+	// Order modules so dependencies are initialized first
+	modules, err := sortModules(a.modules)
+	if err != nil {
+		a.logger.Error("Failed to resolve module dependencies", "error", err)
+		return err
+	}
+	a.modules = modules
+
+	// Load module configuration sections, reporting every problem at once
+	if err := a.configureModules(); err != nil {
+		a.logger.Error("Invalid module configuration", "error", err)
+		return err
+	}
 
 	// Initialize database
-	var err *error
-	a.db, err = a.SetDatabase().OpenDB()
+	a.dbModel = a.SetDatabase()
+	db, err := a.dbModel.OpenDB()
 	if err != nil {
-		a.logger.Error("Failed to initialize database: %v", err)
-		return *err
+		a.logger.Error("Failed to initialize database", "error", err)
+		return err
 	}
+	a.db = db
 
 	// Set database instance for all modules
 	database.DB = a.db
 
+	return nil
+}
+
+// Initialize initializes the application
+func (a *App) Initialize() error {
+	a.logger.Info("Initializing application...")
+
+	if err := a.prepare(); err != nil {
+		return err
+	}
+
 	// event bus initialization
-	event := bus.NewEventBus()
+	a.event = bus.New(bus.Config{
+		Workers:   config.Get().EventBus.Workers,
+		QueueSize: config.Get().EventBus.QueueSize,
+		Logger:    a.logger.WithPrefix("bus"),
+	})
+
+	// application cache
+	a.cache = simplecache.NewSimpleCache(&simplecache.SimpleCache{
+		ExpiredAt: config.Get().Server.CacheExpired,
+		PurgeTime: config.Get().Server.CachePurged,
+	})
+	simplecache.Cache = a.cache.Open()
+	simplecache.Default = a.cache
+
+	// token issuer and authentication middleware shared by the modules
+	issuer, err := a.SetJWT()
+	if err != nil {
+		a.logger.Error("Failed to load JWT keys", "error", err)
+		return err
+	}
+	jwt.SetDefault(issuer)
+	auth.SetDefault(auth.NewAuthenticator(auth.Config{
+		JWT:         jwt.Default(),
+		CookieName:  config.Get().JWT.CookieName,
+		Realm:       config.Get().Server.AppName,
+		Revocations: a.SetRevocations(),
+	}))
+
+	// permissions declared by the modules, checked against the roles
+	a.rbac = rbac.New()
+	rbac.SetDefault(a.rbac)
+	for _, module := range a.modules {
+		if declaring, ok := module.(PermissionModule); ok {
+			if err := a.rbac.Register(module.Name(), declaring.Permissions()...); err != nil {
+				a.logger.Error("Failed to register permissions", "module", module.Name(), "error", err)
+				return err
+			}
+		}
+	}
 
 	// initialize router
+	a.cors = newCORSMiddleware(config.Get().Server.CORS)
 	a.r = a.SetRouter()
+	a.r.Use(middleware.RequestID())
 	a.r.Use(middleware.Logger())
 	a.r.Use(middleware.Recover())
-	a.r.Use(middleware.CORS())
+	a.r.Use(a.cors.handler)
+
+	// errors returned by handlers are rendered as problem+json, internal
+	// details are hidden in production
+	a.r.HTTPErrorHandler = problem.Handler(problem.Config{
+		Production: config.Get().Server.Mode == config.ProductionMode,
+		Logger:     a.logger,
+	})
 
-	// validate request
-	a.r.Validator = _validator.NewCustomValidator()
+	// validate request, with the rules declared by the modules
+	validator := _validator.NewCustomValidator()
+	for _, module := range a.modules {
+		if validating, ok := module.(ValidationModule); ok {
+			for _, rule := range validating.ValidationRules() {
+				if err := validator.RegisterRule(rule); err != nil {
+					a.logger.Error("Failed to register validation rules", "module", module.Name(), "error", err)
+					return err
+				}
+			}
+		}
+	}
+	a.r.Validator = validator
 
 	// Initialize modules
 	for _, module := range a.modules {
-		a.logger.Info("Initializing module: %s", module.Name())
+		a.logger.Info("Initializing module", "module", module.Name())
 
 		// Create module-specific logger
 		moduleLogger := a.logger.WithPrefix(module.Name())
-		if err := module.Initialize(a.db, moduleLogger, event); err != nil {
-			a.logger.Error("Failed to initialize module %s: %v", module.Name(), err)
+		if err := module.Initialize(a.db, moduleLogger, a.event); err != nil {
+			a.logger.Error("Failed to initialize module", "module", module.Name(), "error", err)
 			return err
 		}
 
-		a.logger.Info("Module initialized: %s", module.Name())
+		a.logger.Info("Module initialized", "module", module.Name())
 	}
 
-	// Run migrations for all modules
-	for _, module := range a.modules {
-		err := module.Migrations()
-		if err != nil {
-			a.logger.Error("Failed to run migrations for module %s: %v", module.Name(), err)
+	// Migrations are applied with the migrate command unless auto_migrate is
+	// set, which an in-memory SQLite database needs on every boot
+	if config.Get().Database.AutoMigrate {
+		if _, err := a.migrator().Up(context.Background()); err != nil {
+			a.logger.Error("Failed to run migrations", "error", err)
+			return err
 		}
-		a.logger.Info("Migrations completed for module: %s", module.Name())
+	} else if pending, err := a.migrator().Pending(context.Background()); err != nil {
+		a.logger.Error("Failed to check migration status", "error", err)
+		return err
+	} else if pending > 0 {
+		a.logger.Warn("Pending migrations, run the migrate up command", "pending", pending)
+	}
+
+	// The admin role and its configured subjects; routes guarded with
+	// rbac.Require fail until the roles tables have been migrated
+	if !database.Primary(a.db).Migrator().HasTable("roles") {
+		a.logger.Warn("Roles tables are missing, the admin role has not been created")
+	} else if err := a.rbac.Bootstrap(context.Background(), config.Get().RBAC.AdminRole, config.Get().RBAC.Admins...); err != nil {
+		a.logger.Error("Failed to create the admin role", "error", err)
+		return err
 	}
 
 	// Initialize HTTP server
 	a.server = a.SetServer()
 
+	// public keys verifying the access tokens
+	a.r.GET("/.well-known/jwks.json", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, issuer.JWKS())
+	})
+
 	// api version
-	version := fmt.Sprintf("/api/v%s", config.GetString("server.api_version"))
+	version := fmt.Sprintf("/api/v%s", config.Get().Server.APIVersion)
 
 	// Register routes for all modules
 	for _, module := range a.modules {
-		a.logger.Info("Registering routes for module: %s", module.Name())
+		a.logger.Info("Registering routes", "module", module.Name())
 		module.RegisterRoutes(a.r, version)
-		a.logger.Info("Routes registered for module: %s", module.Name())
+		a.logger.Info("Routes registered", "module", module.Name())
 	}
 
 	// append handler to server
@@ -116,39 +240,281 @@ func (a *App) Initialize() error {
 	a.logger.Info("Application initialization completed")
 
 	for _, v := range a.r.Routes() {
-		fmt.Printf("PATH: %v | METHOD: %v\n", v.Path, v.Method)
+		a.logger.Info("Route registered", "path", v.Path, "method", v.Method)
+	}
+
+	return nil
+}
+
+// Start starts the application and blocks until it receives SIGINT or
+// SIGTERM or the server fails. It always runs the shutdown sequence and
+// returns the process exit code.
+func (a *App) Start() int {
+	code := 0
+
+	if err := a.startModules(context.Background()); err != nil {
+		a.logger.Error("Failed to start modules", "error", err)
+		code = 1
+	} else {
+		// Reload the configuration file when it changes
+		if config.Get().Server.WatchConfig {
+			if err := a.watchConfig(); err != nil {
+				a.logger.Warn("Configuration will not be reloaded", "error", err)
+			}
+		}
+
+		// Handle ctrl+c/ctrl+x interrupt
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+
+		serverErr := make(chan error, 1)
+		go func() {
+			a.logger.Info("Starting server", "address", a.server.Host)
+			if a.tlsEnabled {
+				serverErr <- a.server.RunWithSSL()
+				return
+			}
+			serverErr <- a.server.Run()
+		}()
+
+		select {
+		case sig := <-signals:
+			a.logger.Info("Received signal, shutting down", "signal", sig.String())
+		case err := <-serverErr:
+			if err != nil {
+				a.logger.Error("Server stopped unexpectedly", "error", err)
+				code = 1
+			}
+		}
+	}
+
+	if err := a.Shutdown(); err != nil {
+		code = 1
+	}
+
+	return code
+}
+
+// Shutdown stops the application in order: the HTTP server stops accepting
+// requests and drains in-flight ones, modules are stopped, queued events are
+// processed, the database pool is closed and logs are flushed. Every step
+// shares the server.shutdown_timeout deadline and runs even if a previous
+// one failed.
+func (a *App) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Get().Server.ShutdownTimeout)
+	defer cancel()
+
+	a.logger.Info("Shutting down application...")
+
+	var errs []error
+
+	if err := config.StopWatching(); err != nil {
+		a.logger.Warn("Failed to stop configuration watcher", "error", err)
+	}
+
+	if a.server != nil {
+		if err := a.server.Shutdown(ctx); err != nil {
+			a.logger.Error("Failed to shut down server", "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	// Modules may publish events while they stop, so the bus is drained
+	// after them
+	if err := a.stopModules(ctx); err != nil {
+		errs = append(errs, err)
 	}
 
+	if a.event != nil {
+		if err := a.event.Shutdown(ctx); err != nil {
+			a.logger.Error("Failed to drain event bus", "error", err)
+			errs = append(errs, fmt.Errorf("event bus: %w", err))
+		}
+	}
+
+	if a.db != nil {
+		if err := a.closeDatabase(); err != nil {
+			a.logger.Error("Failed to close database", "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		a.logger.Info("Application shut down cleanly")
+	}
+
+	// Sync errors on stdout/stderr are expected on some platforms and are
+	// not worth failing the shutdown for
+	_ = a.logger.Sync()
+
+	return errors.Join(errs...)
+}
+
+// closeDatabase closes the primary and replica connection pools
+func (a *App) closeDatabase() error {
+	if err := a.dbModel.Close(a.db); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	return nil
+}
+
+// startModules runs the Start hook of every module in dependency order
+func (a *App) startModules(ctx context.Context) error {
+	for _, module := range a.modules {
+		if starter, ok := module.(Starter); ok {
+			a.logger.Info("Starting module", "module", module.Name())
+			if err := starter.Start(ctx); err != nil {
+				return fmt.Errorf("module %s: %w", module.Name(), err)
+			}
+		}
+		a.started = append(a.started, module)
+	}
 	return nil
 }
 
-// Start starts the application
-func (a *App) Start() {
-	a.logger.Info("Starting server on %s", a.server.Host)
-	a.server.Run()
+// stopModules runs the Stop hook of every started module in reverse order
+func (a *App) stopModules(ctx context.Context) error {
+	var errs []error
+	for i := len(a.started) - 1; i >= 0; i-- {
+		module := a.started[i]
+		if stopper, ok := module.(Stopper); ok {
+			a.logger.Info("Stopping module", "module", module.Name())
+			if err := stopper.Stop(ctx); err != nil {
+				a.logger.Error("Failed to stop module", "module", module.Name(), "error", err)
+				errs = append(errs, fmt.Errorf("module %s: %w", module.Name(), err))
+			}
+		}
+	}
+	a.started = nil
+	return errors.Join(errs...)
+}
+
+// configureModules decodes the [modules.<name>] section of every
+// configurable module
+func (a *App) configureModules() error {
+	var errs []error
+	for _, module := range a.modules {
+		configurable, ok := module.(ConfigurableModule)
+		if !ok {
+			continue
+		}
+		if err := config.Decode("modules."+module.Name(), configurable.Config()); err != nil {
+			errs = append(errs, fmt.Errorf("module %s: %w", module.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // setup database model
 func (a *App) SetDatabase() *database.DBModel {
-	return &database.DBModel{
-		ServerMode:   config.GetString("server.mode"),
-		Driver:       config.GetString("database.db_driver"),
-		Host:         config.GetString("database.db_host"),
-		Port:         config.GetString("database.db_port"),
-		Name:         config.GetString("database.db_name"),
-		Username:     config.GetString("database.db_username"),
-		Password:     config.GetString("database.db_password"),
-		MaxIdleConn:  config.GetInt("pool.conn_idle"),
-		MaxOpenConn:  config.GetInt("pool.conn_max"),
-		ConnLifeTime: config.GetInt("pool.conn_lifetime"),
+	cfg := config.Get()
+
+	model := &database.DBModel{
+		ServerMode:    cfg.Server.Mode,
+		Driver:        cfg.Database.Driver,
+		Host:          cfg.Database.Host,
+		Port:          cfg.Database.Port,
+		Name:          cfg.Database.Name,
+		Username:      cfg.Database.Username,
+		Password:      cfg.Database.Password,
+		MaxIdleConn:   cfg.Pool.ConnIdle,
+		MaxOpenConn:   cfg.Pool.ConnMax,
+		ConnLifeTime:  cfg.Pool.ConnLifetime,
+		ReplicaPolicy: cfg.Database.ReplicaPolicy,
+	}
+
+	for _, replica := range cfg.Database.Replicas {
+		model.Replicas = append(model.Replicas, database.ReplicaModel{
+			Host:         replica.Host,
+			Port:         replica.Port,
+			Name:         replica.Name,
+			Username:     replica.Username,
+			Password:     replica.Password,
+			MaxIdleConn:  replica.ConnIdle,
+			MaxOpenConn:  replica.ConnMax,
+			ConnLifeTime: replica.ConnLifetime,
+		})
 	}
+
+	return model
+}
+
+// SetJWT creates the token issuer from the [jwt] section, loading the keys
+// from their PEM files when asymmetric keys are configured
+func (a *App) SetJWT() (*jwt.JWTImpl, error) {
+	cfg := config.Get().JWT
+
+	issuer := &jwt.JWTImpl{
+		SignatureKey: cfg.SignatureKey,
+		TTL:          cfg.AccessTTL,
+		Issuer:       cfg.Issuer,
+		Audience:     cfg.Audience,
+		Leeway:       cfg.Leeway,
+	}
+	if len(cfg.Keys) == 0 {
+		return issuer, nil
+	}
+
+	keys := make([]*jwt.Key, 0, len(cfg.Keys))
+	for _, keyCfg := range cfg.Keys {
+		key, err := jwt.LoadKey(jwt.KeyConfig{
+			ID:             keyCfg.ID,
+			Algorithm:      keyCfg.Algorithm,
+			PrivateKeyFile: keyCfg.PrivateKeyFile,
+			PublicKeyFile:  keyCfg.PublicKeyFile,
+		})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	set, err := jwt.NewKeySet(cfg.SigningKey, keys...)
+	if err != nil {
+		return nil, err
+	}
+	issuer.Keys = set
+
+	return issuer, nil
+}
+
+// SetRevocations creates the store of revoked tokens selected by
+// jwt.revocation_store
+func (a *App) SetRevocations() auth.Revocations {
+	cfg := config.Get().JWT
+
+	// Tokens cannot be valid longer than this after they were issued
+	maxAge := cfg.AccessTTL + cfg.Leeway
+
+	if cfg.RevocationStore == "database" {
+		return auth.NewDatabaseRevocations(maxAge)
+	}
+	return auth.NewMemoryRevocations(simplecache.Cache, maxAge)
 }
 
 // Setup Web Server
 func (a *App) SetServer() *server.ServerContext {
-	return &server.ServerContext{
-		Host:         ":" + config.GetString("server.port"),
-		ReadTimeout:  time.Duration(config.GetInt("server.http_timeout")),
-		WriteTimeout: time.Duration(config.GetInt("server.http_timeout")),
+	cfg := config.Get().Server
+
+	ctx := &server.ServerContext{
+		Host:         ":" + cfg.Port,
+		ReadTimeout:  cfg.HTTPTimeout,
+		WriteTimeout: cfg.HTTPTimeout,
+		Logger:       a.logger,
 	}
+
+	a.tlsEnabled = cfg.TLS.Enabled
+	if a.tlsEnabled {
+		ctx.CertFile = cfg.TLS.CertFile
+		ctx.KeyFile = cfg.TLS.KeyFile
+		ctx.TLS = server.TLSConfig{
+			MinVersion:   cfg.TLS.MinVersion,
+			CipherSuites: cfg.TLS.CipherSuites,
+			ClientCAFile: cfg.TLS.ClientCAFile,
+			ClientAuth:   cfg.TLS.ClientAuth,
+		}
+	}
+
+	return ctx
 }
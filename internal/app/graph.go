@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sortModules orders modules so that every module comes after the modules
+// it depends on. Modules without a dependency relation keep their
+// registration order.
+func sortModules(modules []Module) ([]Module, error) {
+	index := make(map[string]int, len(modules))
+	for i, module := range modules {
+		name := module.Name()
+		if _, exists := index[name]; exists {
+			return nil, fmt.Errorf("module %q registered more than once", name)
+		}
+		index[name] = i
+	}
+
+	// dependencies[i] holds the indexes of the modules that module i needs
+	dependencies := make([][]int, len(modules))
+	for i, module := range modules {
+		dependent, ok := module.(DependentModule)
+		if !ok {
+			continue
+		}
+		for _, name := range dependent.Dependencies() {
+			j, exists := index[name]
+			if !exists {
+				return nil, fmt.Errorf("module %q depends on unregistered module %q", module.Name(), name)
+			}
+			dependencies[i] = append(dependencies[i], j)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(modules))
+	sorted := make([]Module, 0, len(modules))
+	path := make([]string, 0, len(modules))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			// Report the cycle starting from the first occurrence of the module
+			name := modules[i].Name()
+			for k, n := range path {
+				if n == name {
+					cycle := append(append([]string{}, path[k:]...), name)
+					return fmt.Errorf("module dependency cycle detected: %s", strings.Join(cycle, " -> "))
+				}
+			}
+			return fmt.Errorf("module dependency cycle detected at %q", name)
+		}
+
+		state[i] = visiting
+		path = append(path, modules[i].Name())
+		for _, j := range dependencies[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		sorted = append(sorted, modules[i])
+		return nil
+	}
+
+	for i := range modules {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"go-modular-boilerplate/internal/pkg/bus"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"go-modular-boilerplate/internal/pkg/migration"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"gorm.io/gorm"
+)
+
+type testModule struct {
+	name string
+	deps []string
+}
+
+func (m *testModule) Name() string                                             { return m.name }
+func (m *testModule) Initialize(*gorm.DB, *logger.Logger, *bus.EventBus) error { return nil }
+func (m *testModule) RegisterRoutes(*echo.Echo, string)                        {}
+func (m *testModule) Migrations() []migration.Migration                        { return nil }
+func (m *testModule) Logger() *logger.Logger                                   { return nil }
+func (m *testModule) Dependencies() []string                                   { return m.deps }
+
+func names(modules []Module) string {
+	out := make([]string, len(modules))
+	for i, m := range modules {
+		out[i] = m.Name()
+	}
+	return strings.Join(out, ",")
+}
+
+func TestSortModules(t *testing.T) {
+	modules := []Module{
+		&testModule{name: "billing", deps: []string{"user"}},
+		&testModule{name: "audit"},
+		&testModule{name: "user", deps: []string{"audit"}},
+		&testModule{name: "report", deps: []string{"billing", "user"}},
+	}
+
+	sorted, err := sortModules(modules)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := names(sorted), "audit,user,billing,report"; got != want {
+		t.Errorf("got order %s, want %s", got, want)
+	}
+}
+
+func TestSortModulesKeepsRegistrationOrder(t *testing.T) {
+	modules := []Module{
+		&testModule{name: "a"},
+		&testModule{name: "b"},
+		&testModule{name: "c"},
+	}
+
+	sorted, err := sortModules(modules)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := names(sorted), "a,b,c"; got != want {
+		t.Errorf("got order %s, want %s", got, want)
+	}
+}
+
+func TestSortModulesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		modules []Module
+		want    string
+	}{
+		{
+			name: "cycle",
+			modules: []Module{
+				&testModule{name: "a", deps: []string{"b"}},
+				&testModule{name: "b", deps: []string{"c"}},
+				&testModule{name: "c", deps: []string{"a"}},
+			},
+			want: "a -> b -> c -> a",
+		},
+		{
+			name: "missing dependency",
+			modules: []Module{
+				&testModule{name: "billing", deps: []string{"user"}},
+			},
+			want: `unregistered module "user"`,
+		},
+		{
+			name: "duplicate",
+			modules: []Module{
+				&testModule{name: "user"},
+				&testModule{name: "user"},
+			},
+			want: "registered more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sortModules(tt.modules)
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
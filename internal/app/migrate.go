@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/auth"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/migration"
+	"go-modular-boilerplate/internal/pkg/rbac"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// migrator builds a migrator over every registered module, in dependency
+// order, after the tables of the core packages. The revocation tables are
+// created whichever store is configured, so switching jwt.revocation_store
+// later does not leave their migrations unknown.
+func (a *App) migrator() *migration.Migrator {
+	sets := make([]migration.Set, 0, len(a.modules)+2)
+	sets = append(sets,
+		migration.Set{
+			Module:     "auth",
+			Migrations: auth.Migrations(),
+		},
+		migration.Set{
+			Module:     "rbac",
+			Migrations: rbac.Migrations(),
+		},
+	)
+	for _, module := range a.modules {
+		sets = append(sets, migration.Set{
+			Module:     module.Name(),
+			Migrations: module.Migrations(),
+		})
+	}
+	return migration.NewMigrator(database.Primary(a.db), a.logger.WithPrefix("migration"), sets...)
+}
+
+// Migrate runs a migrate subcommand: up, down [n], status or redo. The
+// database is closed and the logs are flushed before it returns.
+func (a *App) Migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [n]|status|redo")
+	}
+
+	if err := a.prepare(); err != nil {
+		return err
+	}
+	defer a.logger.Sync()
+	defer a.closeDatabase()
+
+	ctx := context.Background()
+	m := a.migrator()
+
+	switch args[0] {
+	case "up":
+		count, err := m.Up(ctx)
+		fmt.Printf("Applied %d migration(s)\n", count)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		count, err := m.Down(ctx, steps)
+		fmt.Printf("Rolled back %d migration(s)\n", count)
+		return err
+	case "redo":
+		return m.Redo(ctx)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(statuses)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func printMigrationStatus(statuses []migration.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODULE\tVERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		state := "pending"
+		appliedAt := "-"
+		if s.Applied {
+			state = "applied"
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Modified {
+			state += " (modified)"
+		}
+		if s.Missing {
+			state += " (missing)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", s.Module, s.Version, s.Name, state, appliedAt)
+	}
+	w.Flush()
+}
@@ -1,8 +1,12 @@
 package app
 
 import (
+	"context"
 	"go-modular-boilerplate/internal/pkg/bus"
 	"go-modular-boilerplate/internal/pkg/logger"
+	"go-modular-boilerplate/internal/pkg/migration"
+	"go-modular-boilerplate/internal/pkg/rbac"
+	"go-modular-boilerplate/internal/pkg/validator"
 
 	"github.com/labstack/echo"
 	"gorm.io/gorm"
@@ -19,9 +23,55 @@ type Module interface {
 	// RegisterRoutes registers the module's routes
 	RegisterRoutes(e *echo.Echo, group string)
 
-	// Migrations returns the module's database migrations
-	Migrations() error
+	// Migrations returns the module's versioned database migrations in order
+	Migrations() []migration.Migration
 
 	// Logger returns the module's logger
 	Logger() *logger.Logger
 }
+
+// DependentModule is implemented by modules that require other modules
+// to be initialized before them
+type DependentModule interface {
+	// Dependencies returns the names of the modules this module depends on
+	Dependencies() []string
+}
+
+// Starter is implemented by modules that need to run work once the
+// application has been initialized, e.g. background workers
+type Starter interface {
+	// Start is called in dependency order before the server starts
+	Start(ctx context.Context) error
+}
+
+// Stopper is implemented by modules that hold resources which must be
+// released on shutdown
+type Stopper interface {
+	// Stop is called in reverse dependency order on shutdown
+	Stop(ctx context.Context) error
+}
+
+// ConfigurableModule is implemented by modules that read their own
+// [modules.<name>] section of the configuration file
+type ConfigurableModule interface {
+	// Config returns a pointer to the module's configuration struct. The
+	// section is decoded and validated into it before Initialize; fields
+	// missing from the file keep their current values.
+	Config() interface{}
+}
+
+// PermissionModule is implemented by modules that guard their routes with
+// rbac.Require
+type PermissionModule interface {
+	// Permissions returns the permissions the module's routes check, they
+	// are registered before Initialize
+	Permissions() []rbac.Permission
+}
+
+// ValidationModule is implemented by modules that validate their requests
+// with custom rules
+type ValidationModule interface {
+	// ValidationRules returns the module's rules and their messages, they
+	// are registered before Initialize
+	ValidationRules() []validator.Rule
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"go-modular-boilerplate/internal/pkg/bus"
+	"go-modular-boilerplate/internal/pkg/config"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+)
+
+// watchConfig applies reloaded configuration to the core components and
+// forwards the change to the modules through the event bus
+func (a *App) watchConfig() error {
+	config.OnChange(a.applyConfig)
+	return config.Watch(a.logger)
+}
+
+// applyConfig updates the settings that can change without a restart. The
+// listen address, TLS, database and JWT settings only apply on restart.
+func (a *App) applyConfig(change config.Change) {
+	if change.Old.Log.Level != change.New.Log.Level {
+		a.logger.SetLevel(change.New.Log.Level)
+		a.logger.Info("Log level changed", "level", change.New.Log.Level)
+	}
+
+	if !reflect.DeepEqual(change.Old.Server.CORS, change.New.Server.CORS) {
+		a.cors.set(change.New.Server.CORS)
+		a.logger.Info("CORS origins changed", "origins", change.New.Server.CORS.AllowOrigins)
+	}
+
+	if change.Old.Server.CacheExpired != change.New.Server.CacheExpired && a.cache != nil {
+		a.cache.SetExpiration(change.New.Server.CacheExpired)
+	}
+
+	if a.event != nil {
+		a.event.Publish(bus.Event{Type: config.ChangedEvent, Payload: change})
+	}
+}
+
+// corsMiddleware is a CORS middleware whose allowed origins can be replaced
+// while the server runs
+type corsMiddleware struct {
+	current atomic.Value
+}
+
+func newCORSMiddleware(cfg config.CORSConfig) *corsMiddleware {
+	m := &corsMiddleware{}
+	m.set(cfg)
+	return m
+}
+
+func (m *corsMiddleware) set(cfg config.CORSConfig) {
+	m.current.Store(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: cfg.AllowOrigins,
+	}))
+}
+
+func (m *corsMiddleware) handler(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return m.current.Load().(echo.MiddlewareFunc)(next)(c)
+	}
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"context"
+	"go-modular-boilerplate/internal/pkg/jwt"
+
+	"github.com/labstack/echo"
+)
+
+type claimsKey struct{}
+
+// contextKey is the echo.Context key holding the claims
+const contextKey = "auth.claims"
+
+// WithClaims returns a context carrying claims
+func WithClaims(ctx context.Context, claims *jwt.Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the claims of the authenticated request ctx belongs to
+func FromContext(ctx context.Context) (*jwt.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*jwt.Claims)
+	return claims, ok
+}
+
+// FromEcho returns the claims of the authenticated request
+func FromEcho(c echo.Context) (*jwt.Claims, bool) {
+	claims, ok := c.Get(contextKey).(*jwt.Claims)
+	return claims, ok
+}
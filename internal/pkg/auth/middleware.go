@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"go-modular-boilerplate/internal/pkg/jwt"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo"
+)
+
+// Errors rejecting requests
+var (
+	ErrMissingToken = errs.New(errs.Unauthorized, "missing_token", "missing access token")
+	ErrInvalidToken = errs.New(errs.Unauthorized, "invalid_token", "invalid access token")
+	ErrTokenExpired = errs.New(errs.Unauthorized, "token_expired", "access token has expired")
+	ErrTokenRevoked = errs.New(errs.Unauthorized, "token_revoked", "access token has been revoked")
+)
+
+// Config configures an Authenticator
+type Config struct {
+	// JWT validates the tokens
+	JWT jwt.JWT
+	// CookieName is the cookie read when the request has no Authorization
+	// header, cookies are ignored when it is empty
+	CookieName string
+	// Realm is reported in the WWW-Authenticate header
+	Realm string
+	// Revocations rejects revoked tokens, no token is revoked when it is
+	// nil
+	Revocations Revocations
+}
+
+// Authenticator validates bearer tokens on echo routes
+type Authenticator struct {
+	config Config
+
+	mu     sync.RWMutex
+	public map[string]struct{}
+}
+
+// NewAuthenticator creates a new authenticator
+func NewAuthenticator(cfg Config) *Authenticator {
+	if cfg.Realm == "" {
+		cfg.Realm = "api"
+	}
+	return &Authenticator{
+		config: cfg,
+		public: make(map[string]struct{}),
+	}
+}
+
+// Middleware rejects requests without a valid token with one of the
+// unauthorized errors above, and a WWW-Authenticate challenge, and puts the
+// token's claims on the echo.Context and the request's context.Context.
+// Attach it to a group or to single routes; routes marked with Public are
+// let through.
+func (a *Authenticator) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if a.isPublic(c.Request().Method, c.Path()) {
+				return next(c)
+			}
+
+			token := a.extract(c)
+			if token == "" {
+				return a.unauthorized(c, ErrMissingToken)
+			}
+
+			claims, err := a.config.JWT.ParseToken(token)
+			if err != nil {
+				if errors.Is(err, jwt.ErrTokenExpired) {
+					return a.unauthorized(c, ErrTokenExpired.Wrap(err))
+				}
+				return a.unauthorized(c, ErrInvalidToken.Wrap(err))
+			}
+			if claims.Subject == "" {
+				return a.unauthorized(c, ErrInvalidToken)
+			}
+			if a.config.Revocations != nil {
+				revoked, err := a.config.Revocations.Revoked(c.Request().Context(), claims)
+				if err != nil {
+					return fmt.Errorf("check access token: %w", err)
+				}
+				if revoked {
+					return a.unauthorized(c, ErrTokenRevoked)
+				}
+			}
+
+			c.Set(contextKey, claims)
+			c.SetRequest(c.Request().WithContext(WithClaims(c.Request().Context(), claims)))
+
+			return next(c)
+		}
+	}
+}
+
+// Revocations returns the store of revoked tokens, nil when revocation is
+// not enabled
+func (a *Authenticator) Revocations() Revocations {
+	return a.config.Revocations
+}
+
+// Public lets requests to routes through the middleware without a token,
+// e.g. a sign-up route in an otherwise authenticated group
+func (a *Authenticator) Public(routes ...*echo.Route) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, route := range routes {
+		a.public[route.Method+" "+route.Path] = struct{}{}
+	}
+}
+
+func (a *Authenticator) isPublic(method, path string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	_, ok := a.public[method+" "+path]
+	return ok
+}
+
+// extract returns the bearer token of the Authorization header, or the
+// configured cookie
+func (a *Authenticator) extract(c echo.Context) string {
+	if header := c.Request().Header.Get(echo.HeaderAuthorization); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	}
+
+	if a.config.CookieName != "" {
+		if cookie, err := c.Cookie(a.config.CookieName); err == nil {
+			return cookie.Value
+		}
+	}
+
+	return ""
+}
+
+// unauthorized sets the WWW-Authenticate challenge described in RFC 6750 and
+// returns err for the error handler to respond with 401. Requests without a
+// token get no error code.
+func (a *Authenticator) unauthorized(c echo.Context, err error) error {
+	challenge := fmt.Sprintf("Bearer realm=%q", a.config.Realm)
+	if !errors.Is(err, ErrMissingToken) {
+		challenge += `, error="invalid_token"`
+	}
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+	return err
+}
+
+// defaultAuthenticator is the application's authenticator, set by the
+// application from the [jwt] section
+var defaultAuthenticator *Authenticator
+
+// SetDefault sets the Authenticator returned by Default
+func SetDefault(a *Authenticator) {
+	defaultAuthenticator = a
+}
+
+// Default returns the application's authenticator
+func Default() *Authenticator {
+	return defaultAuthenticator
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"go-modular-boilerplate/internal/pkg/jwt"
+	"go-modular-boilerplate/internal/pkg/problem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func newTestServer(t *testing.T) (*echo.Echo, jwt.JWT) {
+	t.Helper()
+
+	issuer := &jwt.JWTImpl{SignatureKey: "secret", TTL: time.Minute}
+	authenticator := NewAuthenticator(Config{JWT: issuer, CookieName: "access_token"})
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.Handler(problem.Config{})
+	group := e.Group("/things", authenticator.Middleware())
+	group.GET("", func(c echo.Context) error {
+		claims, ok := FromEcho(c)
+		fromContext, _ := FromContext(c.Request().Context())
+		if !ok || fromContext != claims {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.String(http.StatusOK, claims.Subject)
+	})
+	authenticator.Public(group.POST("", func(c echo.Context) error {
+		return c.NoContent(http.StatusCreated)
+	}))
+
+	return e, issuer
+}
+
+func TestMiddleware(t *testing.T) {
+	e, issuer := newTestServer(t)
+
+	token, err := issuer.GenerateToken(jwt.Claims{Subject: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noSubject, _ := issuer.GenerateToken(jwt.Claims{Extra: map[string]interface{}{"email": "a@b.c"}})
+	other := &jwt.JWTImpl{SignatureKey: "other", TTL: time.Minute}
+	forged, _ := other.GenerateToken(jwt.Claims{Subject: "42"})
+
+	tests := []struct {
+		name      string
+		method    string
+		header    string
+		cookie    string
+		status    int
+		body      string
+		challenge string
+	}{
+		{name: "bearer token", method: http.MethodGet, header: "Bearer " + token, status: http.StatusOK, body: "42"},
+		{name: "lower case scheme", method: http.MethodGet, header: "bearer " + token, status: http.StatusOK, body: "42"},
+		{name: "cookie", method: http.MethodGet, cookie: token, status: http.StatusOK, body: "42"},
+		{name: "missing token", method: http.MethodGet, status: http.StatusUnauthorized, challenge: `Bearer realm="api"`},
+		{name: "basic auth", method: http.MethodGet, header: "Basic Zm9vOmJhcg==", status: http.StatusUnauthorized},
+		{name: "wrong key", method: http.MethodGet, header: "Bearer " + forged, status: http.StatusUnauthorized, challenge: `Bearer realm="api", error="invalid_token"`},
+		{name: "garbage", method: http.MethodGet, header: "Bearer not.a.token", status: http.StatusUnauthorized},
+		{name: "no subject", method: http.MethodGet, header: "Bearer " + noSubject, status: http.StatusUnauthorized},
+		{name: "public route", method: http.MethodPost, status: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/things", nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, rec.Body.String())
+			}
+			if tt.status == http.StatusUnauthorized {
+				if rec.Header().Get(echo.HeaderContentType) != problem.ContentType || !strings.Contains(rec.Body.String(), `"code"`) {
+					t.Errorf("expected a problem, got %s", rec.Body.String())
+				}
+				if tt.challenge != "" && rec.Header().Get(echo.HeaderWWWAuthenticate) != tt.challenge {
+					t.Errorf("expected challenge %q, got %q", tt.challenge, rec.Header().Get(echo.HeaderWWWAuthenticate))
+				}
+			}
+		})
+	}
+}
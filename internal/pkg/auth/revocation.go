@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"go-modular-boilerplate/internal/pkg/jwt"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// Revocations records access tokens that must be rejected before they
+// expire: single tokens by their jti, and every token of a subject issued
+// up to a point in time, which is how all sessions of a user are ended
+type Revocations interface {
+	// Revoke rejects the token with the given jti until expiresAt
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeSubject rejects every token of subject issued at or before at
+	RevokeSubject(ctx context.Context, subject string, at time.Time) error
+	// Revoked reports whether the token with these claims has been revoked
+	Revoked(ctx context.Context, claims *jwt.Claims) (bool, error)
+}
+
+// revokedBefore reports whether a token issued at issuedAt is covered by a
+// revocation of its subject at cutoff. Tokens carry iat in milliseconds, so
+// only a token issued in the same millisecond as the revocation is revoked
+// with it; one issued right after, e.g. by logging in again, stays valid.
+func revokedBefore(issuedAt, cutoff time.Time) bool {
+	return !issuedAt.After(cutoff.Truncate(time.Millisecond))
+}
+
+// MemoryRevocations keeps revocations in the application cache. They are
+// lost on restart and not shared between instances; use
+// DatabaseRevocations when running more than one.
+type MemoryRevocations struct {
+	cache *cache.Cache
+	// maxAge is the longest a token stays valid after it was issued,
+	// subject revocations are dropped after it
+	maxAge time.Duration
+}
+
+// NewMemoryRevocations creates revocations stored in c. maxAge is the
+// access token lifetime plus the validation leeway.
+func NewMemoryRevocations(c *cache.Cache, maxAge time.Duration) *MemoryRevocations {
+	return &MemoryRevocations{cache: c, maxAge: maxAge}
+}
+
+// Revoke implements Revocations.
+func (m *MemoryRevocations) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := m.maxAge
+	if !expiresAt.IsZero() {
+		ttl = time.Until(expiresAt)
+	}
+	if ttl <= 0 {
+		// Already expired, the token is rejected anyway
+		return nil
+	}
+	m.cache.Set("auth.revoked.jti:"+jti, struct{}{}, ttl)
+	return nil
+}
+
+// RevokeSubject implements Revocations.
+func (m *MemoryRevocations) RevokeSubject(ctx context.Context, subject string, at time.Time) error {
+	m.cache.Set("auth.revoked.sub:"+subject, at, m.maxAge)
+	return nil
+}
+
+// Revoked implements Revocations.
+func (m *MemoryRevocations) Revoked(ctx context.Context, claims *jwt.Claims) (bool, error) {
+	if claims.ID != "" {
+		if _, found := m.cache.Get("auth.revoked.jti:" + claims.ID); found {
+			return true, nil
+		}
+	}
+	if cutoff, found := m.cache.Get("auth.revoked.sub:" + claims.Subject); found {
+		return revokedBefore(claims.IssuedAt, cutoff.(time.Time)), nil
+	}
+	return false, nil
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/jwt"
+	"go-modular-boilerplate/internal/pkg/migration"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// revokedToken is a token revoked by its jti
+type revokedToken struct {
+	JTI       string    `gorm:"column:jti;primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (revokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// revokedSubject revokes the tokens of a subject issued at or before
+// RevokedAt
+type revokedSubject struct {
+	Subject   string `gorm:"primaryKey;size:64"`
+	RevokedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (revokedSubject) TableName() string {
+	return "revoked_subjects"
+}
+
+// DatabaseRevocations keeps revocations in the database so every instance
+// of the application sees them and they survive restarts. Its tables are
+// created by the migrations returned by Migrations.
+type DatabaseRevocations struct {
+	// maxAge is the longest a token stays valid after it was issued,
+	// subject revocations are purged after it
+	maxAge time.Duration
+}
+
+// NewDatabaseRevocations creates revocations stored through
+// database.Conn. maxAge is the access token lifetime plus the validation
+// leeway.
+func NewDatabaseRevocations(maxAge time.Duration) *DatabaseRevocations {
+	return &DatabaseRevocations{maxAge: maxAge}
+}
+
+// Revoke implements Revocations.
+func (d *DatabaseRevocations) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(d.maxAge)
+	}
+	if err := d.purge(ctx); err != nil {
+		return err
+	}
+	return database.Conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&revokedToken{JTI: jti, ExpiresAt: expiresAt.UTC()}).Error
+}
+
+// RevokeSubject implements Revocations.
+func (d *DatabaseRevocations) RevokeSubject(ctx context.Context, subject string, at time.Time) error {
+	if err := d.purge(ctx); err != nil {
+		return err
+	}
+	return database.Conn(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_at", "expires_at"}),
+	}).Create(&revokedSubject{
+		Subject:   subject,
+		RevokedAt: at.UTC(),
+		ExpiresAt: at.Add(d.maxAge).UTC(),
+	}).Error
+}
+
+// Revoked implements Revocations. It reads from the primary so a
+// revocation applies immediately.
+func (d *DatabaseRevocations) Revoked(ctx context.Context, claims *jwt.Claims) (bool, error) {
+	ctx = database.WithPrimary(ctx)
+	now := time.Now()
+
+	if claims.ID != "" {
+		var tokens []revokedToken
+		if err := database.Conn(ctx).Where("jti = ?", claims.ID).Limit(1).Find(&tokens).Error; err != nil {
+			return false, err
+		}
+		if len(tokens) > 0 && tokens[0].ExpiresAt.After(now) {
+			return true, nil
+		}
+	}
+
+	var subjects []revokedSubject
+	if err := database.Conn(ctx).Where("subject = ?", claims.Subject).Limit(1).Find(&subjects).Error; err != nil {
+		return false, err
+	}
+	if len(subjects) > 0 && subjects[0].ExpiresAt.After(now) {
+		return revokedBefore(claims.IssuedAt, subjects[0].RevokedAt), nil
+	}
+
+	return false, nil
+}
+
+// purge deletes revocations of tokens that have expired since
+func (d *DatabaseRevocations) purge(ctx context.Context) error {
+	now := time.Now().UTC()
+	if err := database.Conn(ctx).Where("expires_at <= ?", now).Delete(&revokedToken{}).Error; err != nil {
+		return err
+	}
+	return database.Conn(ctx).Where("expires_at <= ?", now).Delete(&revokedSubject{}).Error
+}
+
+// Migrations returns the migrations creating the tables of
+// DatabaseRevocations
+func Migrations() []migration.Migration {
+	return []migration.Migration{
+		{
+			Version: 1,
+			Name:    "create_revocations",
+			Up: func(tx *gorm.DB) error {
+				return tx.Migrator().CreateTable(&revokedTokenV1{}, &revokedSubjectV1{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&revokedTokenV1{}, &revokedSubjectV1{})
+			},
+		},
+	}
+}
+
+// revokedTokenV1 and revokedSubjectV1 are the tables as created by the
+// first migration
+type revokedTokenV1 struct {
+	JTI       string    `gorm:"column:jti;primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (revokedTokenV1) TableName() string {
+	return "revoked_tokens"
+}
+
+type revokedSubjectV1 struct {
+	Subject   string `gorm:"primaryKey;size:64"`
+	RevokedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (revokedSubjectV1) TableName() string {
+	return "revoked_subjects"
+}
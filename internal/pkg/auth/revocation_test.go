@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/jwt"
+	"go-modular-boilerplate/internal/pkg/problem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/patrickmn/go-cache"
+)
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+
+	model := &database.DBModel{Driver: "sqlite", Name: database.SQLITE_MEMORY}
+	db, err := model.OpenDB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := Migrations()[0].Up(db); err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+}
+
+func TestRevocations(t *testing.T) {
+	stores := map[string]func(t *testing.T) Revocations{
+		"memory": func(t *testing.T) Revocations {
+			return NewMemoryRevocations(cache.New(time.Minute, time.Minute), time.Hour)
+		},
+		"database": func(t *testing.T) Revocations {
+			openTestDB(t)
+			return NewDatabaseRevocations(time.Hour)
+		},
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+			now := time.Now()
+
+			revoked := func(claims *jwt.Claims) bool {
+				t.Helper()
+				ok, err := store.Revoked(ctx, claims)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ok
+			}
+
+			first := &jwt.Claims{ID: "a", Subject: "1", IssuedAt: now.Add(-time.Minute)}
+			second := &jwt.Claims{ID: "b", Subject: "1", IssuedAt: now.Add(-time.Minute)}
+			if revoked(first) {
+				t.Fatal("token revoked before Revoke")
+			}
+
+			if err := store.Revoke(ctx, "a", now.Add(time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			// Revoking twice is not an error
+			if err := store.Revoke(ctx, "a", now.Add(time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			if !revoked(first) || revoked(second) {
+				t.Fatal("expected only the first token to be revoked")
+			}
+
+			if err := store.RevokeSubject(ctx, "1", now); err != nil {
+				t.Fatal(err)
+			}
+			// A token issued in the same second, e.g. by logging in again
+			later := &jwt.Claims{ID: "c", Subject: "1", IssuedAt: now.Add(10 * time.Millisecond)}
+			other := &jwt.Claims{ID: "d", Subject: "2", IssuedAt: now.Add(-time.Minute)}
+			if !revoked(second) {
+				t.Error("expected tokens issued before the subject revocation to be revoked")
+			}
+			if revoked(later) || revoked(other) {
+				t.Error("expected later tokens and other subjects to stay valid")
+			}
+		})
+	}
+}
+
+func TestMiddlewareRejectsRevokedTokens(t *testing.T) {
+	issuer := &jwt.JWTImpl{SignatureKey: "secret", TTL: time.Minute}
+	store := NewMemoryRevocations(cache.New(time.Minute, time.Minute), time.Hour)
+	authenticator := NewAuthenticator(Config{JWT: issuer, Revocations: store})
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.Handler(problem.Config{})
+	e.GET("/things", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, authenticator.Middleware())
+
+	token, err := issuer.GenerateToken(jwt.Claims{Subject: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := issuer.ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/things", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 before revocation, got %d", rec.Code)
+	}
+
+	if err := store.Revoke(context.Background(), claims.ID, claims.ExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := get()
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after revocation, got %d", rec.Code)
+	}
+	if rec.Header().Get(echo.HeaderWWWAuthenticate) != `Bearer realm="api", error="invalid_token"` {
+		t.Errorf("unexpected challenge %q", rec.Header().Get(echo.HeaderWWWAuthenticate))
+	}
+}
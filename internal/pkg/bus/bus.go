@@ -1,6 +1,15 @@
 package bus
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
 
 // Event represents an event in our system
 type Event struct {
@@ -21,61 +30,203 @@ func (f EventHandlerFunc) Handle(event Event) {
 	f(event)
 }
 
+// ErrorHandler is an event handler that can fail. Its errors are logged with
+// the event type and the handler's name.
+type ErrorHandler interface {
+	HandleEvent(event Event) error
+}
+
+// ErrorHandlerFunc is a function type that implements ErrorHandler
+type ErrorHandlerFunc func(event Event) error
+
+// HandleEvent calls the function itself
+func (f ErrorHandlerFunc) HandleEvent(event Event) error {
+	return f(event)
+}
+
+// Config configures an EventBus
+type Config struct {
+	// Workers is the number of events handled at the same time, 1 when
+	// zero. The handlers of an event run one after the other on the same
+	// worker, in the order they subscribed.
+	Workers int
+	// QueueSize is the number of events buffered before Publish blocks,
+	// 100 when zero
+	QueueSize int
+	// Logger reports the handlers that fail or panic, logger.Default()
+	// when nil
+	Logger *logger.Logger
+}
+
+// DefaultConfig returns the configuration of NewEventBus
+func DefaultConfig() Config {
+	return Config{
+		Workers:   4,
+		QueueSize: 100,
+	}
+}
+
+// subscription is a subscribed handler with the name it is logged with
+type subscription struct {
+	name   string
+	handle func(event Event) error
+}
+
 // EventBus manages the event distribution
 type EventBus struct {
 	eventChannel chan Event
-	handlers     map[string][]EventHandler
+	handlers     map[string][]subscription
+	log          *logger.Logger
 	mu           sync.RWMutex
 	wg           sync.WaitGroup
+	// closeMu guards closed and the additions to sending. It is never held
+	// while sending to eventChannel, so a full queue cannot block Close.
+	closeMu sync.Mutex
+	closed  bool
+	// sending counts the Publish calls that are sending to eventChannel,
+	// which is only closed once they are done
+	sending sync.WaitGroup
 }
 
-// NewEventBus creates a new event bus
+// NewEventBus creates a new event bus with the default configuration
 func NewEventBus() *EventBus {
+	return New(DefaultConfig())
+}
+
+// New creates a new event bus and starts its workers
+func New(config Config) *EventBus {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+
 	bus := &EventBus{
-		eventChannel: make(chan Event, 100), // Buffer size of 100 events
-		handlers:     make(map[string][]EventHandler),
+		eventChannel: make(chan Event, config.QueueSize),
+		handlers:     make(map[string][]subscription),
+		log:          config.Logger,
+	}
+	for i := 0; i < config.Workers; i++ {
+		go bus.processEvents()
 	}
-	go bus.processEvents()
 	return bus
 }
 
 // Subscribe registers a handler for a specific event type
 func (bus *EventBus) Subscribe(eventType string, handler EventHandler) {
-	bus.mu.Lock()
-	defer bus.mu.Unlock()
-	bus.handlers[eventType] = append(bus.handlers[eventType], handler)
+	bus.subscribe(eventType, subscription{
+		name: handlerName(handler),
+		handle: func(event Event) error {
+			handler.Handle(event)
+			return nil
+		},
+	})
 }
 
 // SubscribeFunc registers a function as a handler for a specific event type
 func (bus *EventBus) SubscribeFunc(eventType string, handlerFunc func(event Event)) {
-	bus.Subscribe(eventType, EventHandlerFunc(handlerFunc))
+	bus.subscribe(eventType, subscription{
+		name: handlerName(handlerFunc),
+		handle: func(event Event) error {
+			handlerFunc(event)
+			return nil
+		},
+	})
+}
+
+// SubscribeHandler registers a handler that can fail for a specific event
+// type
+func (bus *EventBus) SubscribeHandler(eventType string, handler ErrorHandler) {
+	bus.subscribe(eventType, subscription{name: handlerName(handler), handle: handler.HandleEvent})
+}
+
+// SubscribeErrorFunc registers a function that can fail as a handler for a
+// specific event type
+func (bus *EventBus) SubscribeErrorFunc(eventType string, handlerFunc func(event Event) error) {
+	bus.subscribe(eventType, subscription{name: handlerName(handlerFunc), handle: handlerFunc})
 }
 
-// Publish sends an event to the event bus
+func (bus *EventBus) subscribe(eventType string, sub subscription) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.handlers[eventType] = append(bus.handlers[eventType], sub)
+}
+
+// Publish sends an event to the event bus. Events published after the bus
+// has been closed are dropped.
 func (bus *EventBus) Publish(event Event) {
+	bus.closeMu.Lock()
+	if bus.closed {
+		bus.closeMu.Unlock()
+		return
+	}
+	bus.sending.Add(1)
 	bus.wg.Add(1)
+	bus.closeMu.Unlock()
+
+	defer bus.sending.Done()
 	bus.eventChannel <- event
 }
 
-// processEvents processes events from the event channel
+// processEvents runs the handlers of the events from the event channel until
+// it is closed
 func (bus *EventBus) processEvents() {
 	for event := range bus.eventChannel {
 		bus.mu.RLock()
-		handlers, exists := bus.handlers[event.Type]
+		handlers := bus.handlers[event.Type]
 		bus.mu.RUnlock()
 
-		if exists {
-			for _, handler := range handlers {
-				// Create a closure to ensure we use the correct handler and event
-				func(handler EventHandler, event Event) {
-					defer bus.wg.Done()
-					handler.Handle(event)
-				}(handler, event)
-			}
-		} else {
-			bus.wg.Done()
+		for _, sub := range handlers {
+			bus.handle(sub, event)
+		}
+		bus.wg.Done()
+	}
+}
+
+// handle runs a handler, logging its error or panic so the other handlers
+// and events are not affected
+func (bus *EventBus) handle(sub subscription, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			bus.logger().Error("Event handler panicked",
+				"event", event.Type,
+				"handler", sub.name,
+				"panic", fmt.Sprint(r),
+				"stack", string(debug.Stack()),
+			)
+		}
+	}()
+
+	if err := sub.handle(event); err != nil {
+		bus.logger().Error("Event handler failed",
+			"event", event.Type,
+			"handler", sub.name,
+			"error", err.Error(),
+		)
+	}
+}
+
+// logger returns the configured logger, the default one is only created
+// once something has to be logged
+func (bus *EventBus) logger() *logger.Logger {
+	if bus.log != nil {
+		return bus.log
+	}
+	return logger.Default()
+}
+
+// handlerName names a handler after its function, or its type for other
+// handlers, e.g. "go-modular-boilerplate/modules/users/handler.(*UserHandler).Handle"
+func handlerName(handler interface{}) string {
+	value := reflect.ValueOf(handler)
+	if value.Kind() == reflect.Func {
+		if fn := runtime.FuncForPC(value.Pointer()); fn != nil {
+			// Method values are suffixed with -fm
+			return strings.TrimSuffix(fn.Name(), "-fm")
 		}
 	}
+	return fmt.Sprintf("%T", handler)
 }
 
 // Wait waits for all published events to be processed
@@ -83,7 +234,36 @@ func (bus *EventBus) Wait() {
 	bus.wg.Wait()
 }
 
-// Close shuts down the event bus
+// Close stops accepting new events and waits for the Publish calls blocked
+// on a full queue to hand over their events. Events already queued are still
+// processed; use Wait or Shutdown to wait for them.
 func (bus *EventBus) Close() {
+	bus.closeMu.Lock()
+	if bus.closed {
+		bus.closeMu.Unlock()
+		return
+	}
+	bus.closed = true
+	bus.closeMu.Unlock()
+
+	bus.sending.Wait()
 	close(bus.eventChannel)
 }
+
+// Shutdown closes the bus and waits for queued events to be processed
+// until ctx is done
+func (bus *EventBus) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		bus.Close()
+		bus.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
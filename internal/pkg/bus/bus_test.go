@@ -1,6 +1,16 @@
 package bus
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 type testHandler struct {
 	called bool
@@ -27,3 +37,138 @@ func TestEventBus(t *testing.T) {
 
 	t.Log("EventBus test passed")
 }
+
+func TestEventBusShutdownDrainsQueuedEvents(t *testing.T) {
+	bus := NewEventBus()
+
+	var count atomic.Int32
+	bus.SubscribeFunc("test", func(event Event) {
+		count.Add(1)
+	})
+
+	for i := 0; i < 10; i++ {
+		bus.Publish(Event{Type: "test"})
+	}
+
+	if err := bus.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned error: %v", err)
+	}
+
+	if count.Load() != 10 {
+		t.Errorf("expected 10 handled events, got %d", count.Load())
+	}
+
+	// Publishing after shutdown must not panic
+	bus.Publish(Event{Type: "test"})
+}
+
+type failingHandler struct{}
+
+func (failingHandler) HandleEvent(event Event) error {
+	return errors.New("mailbox full")
+}
+
+func TestEventBusIsolatesFailingHandlers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bus.log")
+	log, err := logger.NewLogger(logger.Config{Level: logger.InfoLevel, Encoding: "json", OutputPath: path}, "bus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := New(Config{Workers: 2, Logger: log})
+
+	var handled atomic.Int32
+	bus.SubscribeFunc("test", func(event Event) {
+		panic("boom")
+	})
+	bus.SubscribeHandler("test", failingHandler{})
+	bus.SubscribeErrorFunc("test", func(event Event) error {
+		handled.Add(1)
+		return nil
+	})
+
+	bus.Publish(Event{Type: "test"})
+	bus.Publish(Event{Type: "test"})
+	if err := bus.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if handled.Load() != 2 {
+		t.Errorf("expected the last handler to run for both events, got %d", handled.Load())
+	}
+
+	_ = log.Sync()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := string(data)
+	for _, want := range []string{
+		`"message":"Event handler panicked","event":"test","handler":"go-modular-boilerplate/internal/pkg/bus.TestEventBusIsolatesFailingHandlers.func1","panic":"boom"`,
+		`"message":"Event handler failed","event":"test","handler":"bus.failingHandler","error":"mailbox full"`,
+	} {
+		if strings.Count(logged, want) != 2 {
+			t.Errorf("expected %s to be logged twice, got:\n%s", want, logged)
+		}
+	}
+}
+
+func TestEventBusWorkersRunConcurrently(t *testing.T) {
+	bus := New(Config{Workers: 2})
+
+	release := make(chan struct{})
+	bus.SubscribeFunc("slow", func(event Event) {
+		<-release
+	})
+	done := make(chan struct{})
+	bus.SubscribeFunc("fast", func(event Event) {
+		close(done)
+	})
+
+	bus.Publish(Event{Type: "slow"})
+	bus.Publish(Event{Type: "fast"})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("a slow handler blocked the other worker")
+	}
+	close(release)
+	bus.Wait()
+}
+
+func TestEventBusShutdownWithHandlerPublishingOnFullQueue(t *testing.T) {
+	bus := New(Config{Workers: 1, QueueSize: 1})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	bus.SubscribeFunc("block", func(event Event) {
+		close(started)
+		<-release
+		// Published while the bus is shutting down
+		bus.Publish(Event{Type: "late"})
+	})
+
+	bus.Publish(Event{Type: "block"})
+	<-started
+	// Fills the queue, the next publisher blocks on it
+	bus.Publish(Event{Type: "fill"})
+	go bus.Publish(Event{Type: "blocked"})
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- bus.Shutdown(ctx)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Shutdown returned error: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Shutdown did not return")
+	}
+}
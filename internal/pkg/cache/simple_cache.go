@@ -1,6 +1,7 @@
 package simplecache
 
 import (
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -8,10 +9,16 @@ import (
 
 var Cache *cache.Cache
 
+// Default is the application cache, its expiration follows the
+// server.cache_expired setting when the configuration is reloaded
+var Default ICache
+
 type SimpleCache struct {
 	Cache     *cache.Cache
 	ExpiredAt int
 	PurgeTime int
+
+	mu sync.RWMutex
 }
 
 type ICache interface {
@@ -19,9 +26,10 @@ type ICache interface {
 	Set(key string, data interface{})
 	Get(key string) *interface{}
 	Delete(key string)
+	SetExpiration(minutes int)
 }
 
-func NewSimpleCache(s SimpleCache) ICache {
+func NewSimpleCache(s *SimpleCache) ICache {
 
 	return &SimpleCache{
 		ExpiredAt: s.ExpiredAt,
@@ -37,7 +45,18 @@ func (s *SimpleCache) Open() *cache.Cache {
 }
 
 func (s *SimpleCache) Set(key string, data interface{}) {
-	s.Cache.Set(key, data, time.Minute*time.Duration(s.ExpiredAt))
+	s.mu.RLock()
+	expiration := time.Minute * time.Duration(s.ExpiredAt)
+	s.mu.RUnlock()
+
+	s.Cache.Set(key, data, expiration)
+}
+
+// SetExpiration changes the expiration of entries set from now on
+func (s *SimpleCache) SetExpiration(minutes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ExpiredAt = minutes
 }
 
 func (s *SimpleCache) Get(key string) *interface{} {
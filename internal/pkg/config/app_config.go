@@ -0,0 +1,163 @@
+package config
+
+import "time"
+
+// AppConfig is the typed form of the configuration file
+type AppConfig struct {
+	Server   ServerConfig   `mapstructure:"server"`
+	Database DatabaseConfig `mapstructure:"database"`
+	Pool     PoolConfig     `mapstructure:"pool"`
+	JWT      JWTConfig      `mapstructure:"jwt"`
+	Log      LogConfig      `mapstructure:"log"`
+	RBAC     RBACConfig     `mapstructure:"rbac"`
+	EventBus EventBusConfig `mapstructure:"event_bus"`
+}
+
+// ServerConfig holds the [server] section
+type ServerConfig struct {
+	AppName         string        `mapstructure:"app_name" validate:"required"`
+	Mode            string        `mapstructure:"mode"`
+	Port            string        `mapstructure:"port" validate:"required,numeric"`
+	HTTPTimeout     time.Duration `mapstructure:"http_timeout" validate:"gt=0"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout" validate:"gt=0"`
+	CacheExpired    int           `mapstructure:"cache_expired" validate:"gte=0"`
+	CachePurged     int           `mapstructure:"cache_purged" validate:"gte=0"`
+	APIVersion      string        `mapstructure:"api_version" validate:"required"`
+	WatchConfig     bool          `mapstructure:"watch_config"`
+	TLS             TLSConfig     `mapstructure:"tls"`
+	CORS            CORSConfig    `mapstructure:"cors"`
+}
+
+// ProductionMode is the server mode hiding the details of internal errors
+// from clients
+const ProductionMode = "production"
+
+// CORSConfig holds the [server.cors] section
+type CORSConfig struct {
+	AllowOrigins []string `mapstructure:"allow_origins"`
+}
+
+// TLSConfig holds the [server.tls] section
+type TLSConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	CertFile     string   `mapstructure:"cert_file"`
+	KeyFile      string   `mapstructure:"key_file"`
+	MinVersion   string   `mapstructure:"min_version" validate:"omitempty,oneof=1.2 1.3"`
+	CipherSuites []string `mapstructure:"cipher_suites"`
+	ClientCAFile string   `mapstructure:"client_ca_file"`
+	ClientAuth   string   `mapstructure:"client_auth" validate:"omitempty,oneof=none request require verify_if_given require_and_verify"`
+}
+
+// DatabaseConfig holds the [database] section
+type DatabaseConfig struct {
+	Driver        string          `mapstructure:"db_driver" validate:"required,oneof=mysql postgres sqlite"`
+	Host          string          `mapstructure:"db_host"`
+	Port          string          `mapstructure:"db_port"`
+	Name          string          `mapstructure:"db_name" validate:"required"`
+	Username      string          `mapstructure:"db_username"`
+	Password      string          `mapstructure:"db_password"`
+	AutoMigrate   bool            `mapstructure:"auto_migrate"`
+	ReplicaPolicy string          `mapstructure:"replica_policy" validate:"omitempty,oneof=random round_robin"`
+	Replicas      []ReplicaConfig `mapstructure:"replicas" validate:"dive"`
+}
+
+// ReplicaConfig holds a [[database.replicas]] entry
+type ReplicaConfig struct {
+	Host         string        `mapstructure:"db_host" validate:"required"`
+	Port         string        `mapstructure:"db_port"`
+	Name         string        `mapstructure:"db_name"`
+	Username     string        `mapstructure:"db_username"`
+	Password     string        `mapstructure:"db_password"`
+	ConnIdle     int           `mapstructure:"conn_idle" validate:"gte=0"`
+	ConnMax      int           `mapstructure:"conn_max" validate:"gte=0"`
+	ConnLifetime time.Duration `mapstructure:"conn_lifetime" validate:"gte=0"`
+}
+
+// PoolConfig holds the [pool] section
+type PoolConfig struct {
+	ConnIdle     int           `mapstructure:"conn_idle" validate:"gte=0"`
+	ConnMax      int           `mapstructure:"conn_max" validate:"gte=0"`
+	ConnLifetime time.Duration `mapstructure:"conn_lifetime" validate:"gte=0"`
+}
+
+// JWTConfig holds the [jwt] section
+type JWTConfig struct {
+	AccessTTL time.Duration `mapstructure:"access_ttl" validate:"gt=0"`
+	// Issuer is the iss claim of issued tokens, required on validated ones
+	Issuer string `mapstructure:"issuer"`
+	// Audience is the aud claim of issued tokens; validated tokens must
+	// name one of them
+	Audience []string `mapstructure:"audience"`
+	// Leeway tolerates clock skew when checking exp, nbf and iat
+	Leeway time.Duration `mapstructure:"leeway" validate:"gte=0"`
+	// SignatureKey signs tokens with HS256 when no keys are configured
+	SignatureKey string `mapstructure:"signature_key"`
+	// SigningKey is the id of the key in Keys that signs new tokens
+	SigningKey string         `mapstructure:"signing_key"`
+	Keys       []JWTKeyConfig `mapstructure:"keys" validate:"dive"`
+	CookieName string         `mapstructure:"cookie_name"`
+	// RevocationStore keeps revoked tokens in the cache of this instance
+	// (memory) or in the database, shared by every instance
+	RevocationStore string `mapstructure:"revocation_store" validate:"oneof=memory database"`
+}
+
+// JWTKeyConfig holds a [[jwt.keys]] entry
+type JWTKeyConfig struct {
+	ID             string `mapstructure:"id" validate:"required"`
+	Algorithm      string `mapstructure:"algorithm" validate:"oneof=RS256 RS384 RS512 ES256 ES384 ES512 EdDSA"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+}
+
+// LogConfig holds the [log] section
+type LogConfig struct {
+	Level      string `mapstructure:"level" validate:"oneof=debug info warn error fatal"`
+	Encoding   string `mapstructure:"encoding" validate:"oneof=json console"`
+	OutputPath string `mapstructure:"output_path" validate:"required"`
+}
+
+// RBACConfig holds the [rbac] section
+type RBACConfig struct {
+	// AdminRole is the role holding every permission, created on startup
+	AdminRole string `mapstructure:"admin_role" validate:"required"`
+	// Admins are the token subjects (user IDs) given the admin role on
+	// startup
+	Admins []string `mapstructure:"admins"`
+}
+
+// EventBusConfig holds the [event_bus] section
+type EventBusConfig struct {
+	// Workers is the number of events handled at the same time
+	Workers int `mapstructure:"workers" validate:"gt=0"`
+	// QueueSize is the number of events buffered before publishing blocks
+	QueueSize int `mapstructure:"queue_size" validate:"gt=0"`
+}
+
+// defaults are applied to keys missing from the configuration file
+var defaults = map[string]interface{}{
+	"server.mode":               "info",
+	"server.http_timeout":       "60s",
+	"server.shutdown_timeout":   "30s",
+	"server.cache_expired":      24,
+	"server.cache_purged":       60,
+	"server.api_version":        "1",
+	"server.watch_config":       true,
+	"server.tls.enabled":        false,
+	"server.tls.min_version":    "1.2",
+	"server.tls.client_auth":    "none",
+	"server.cors.allow_origins": []string{"*"},
+	"database.auto_migrate":     false,
+	"database.replica_policy":   "random",
+	"pool.conn_idle":            10,
+	"pool.conn_max":             100,
+	"pool.conn_lifetime":        "1h",
+	"jwt.access_ttl":            "15m",
+	"jwt.leeway":                "30s",
+	"jwt.revocation_store":      "memory",
+	"log.level":                 "info",
+	"log.encoding":              "json",
+	"log.output_path":           "logs/app.log",
+	"rbac.admin_role":           "admin",
+	"event_bus.workers":         4,
+	"event_bus.queue_size":      100,
+}
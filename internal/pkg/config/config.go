@@ -1,10 +1,15 @@
 package config
 
 import (
+	"bytes"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,35 +18,220 @@ type Config struct {
 	filename string
 }
 
+// snapshot is a loaded and validated version of the configuration. It is
+// never modified once active: registering a section or a default derives a
+// new snapshot from the same file content instead, so readers never see a
+// viper instance being written to.
+type snapshot struct {
+	v   *viper.Viper
+	cfg *AppConfig
+
+	// content and format are the file the snapshot was read from
+	content []byte
+	format  string
+
+	// sections are the module sections whose environment variables and
+	// secret files are applied to v
+	sections map[string]reflect.Type
+}
+
+var (
+	// active is the snapshot returned by Get and the Get* helpers, it is
+	// swapped as a whole when the file is reloaded
+	active atomic.Pointer[snapshot]
+
+	// filename is the file loaded by Initialize, watched by Watch
+	filename string
+
+	// sections are the module sections decoded with Decode, re-validated on
+	// every reload
+	sectionsMu sync.Mutex
+	sections   map[string]interface{}
+)
+
 func NewConfig(filename string) Config {
 	return Config{filename: filename}
 }
+
+// Initialize reads the configuration file, applies defaults and environment
+// overrides and validates it into the typed configuration returned by Get.
+// All problems are reported at once in a *ValidationError.
 func (c *Config) Initialize() error {
+	sectionsMu.Lock()
+	sections = make(map[string]interface{})
+	sectionsMu.Unlock()
 
-	configName := filepath.Base(c.filename)
+	s, err := loadFile(c.filename)
+	if err != nil {
+		return err
+	}
 
-	configExtension := filepath.Ext(c.filename)
-	configExtension = strings.TrimPrefix(configExtension, ".")
+	filename = c.filename
+	active.Store(s)
 
-	viper.SetConfigName(configName)
-	viper.SetConfigType(configExtension)
-	viper.AddConfigPath(filepath.Dir(c.filename))
+	return nil
+}
 
-	viper.AutomaticEnv()
-	err := viper.ReadInConfig()
+// loadFile reads and validates file into a new snapshot, applying the
+// environment of every module section decoded so far
+func loadFile(file string) (*snapshot, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
 
+	s, err := prepare(content, strings.TrimPrefix(filepath.Ext(file), "."), registered())
 	if err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+		return nil, err
+	}
+
+	s.cfg = &AppConfig{}
+	if err := decode(s.v, "", s.cfg); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// prepare reads content into a new snapshot with defaults, environment
+// overrides and secret files applied, without decoding it
+func prepare(content []byte, format string, sections map[string]reflect.Type) (*snapshot, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	// APP_DATABASE_DB_PASSWORD overrides database.db_password
+	setupEnv(v)
+	bindEnvs(v, "", reflect.TypeOf(AppConfig{}))
+	for key, t := range sections {
+		bindEnvs(v, key, t)
+	}
+
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+
+	// APP_DATABASE_DB_PASSWORD_FILE reads it from a mounted secret
+	if err := applySecretFiles(v); err != nil {
+		return nil, err
+	}
+
+	return &snapshot{v: v, content: content, format: format, sections: sections}, nil
+}
+
+// derive prepares a new snapshot of the active configuration with the
+// current defaults and module sections and makes it active. The typed
+// configuration is kept as is. It does not take reloadMu so OnChange
+// listeners can call Decode; a concurrent reload is retried against.
+func derive() (*snapshot, error) {
+	for {
+		old := current()
+		s, err := prepare(old.content, old.format, registered())
+		if err != nil {
+			return nil, err
+		}
+		s.cfg = old.cfg
+
+		if active.CompareAndSwap(old, s) {
+			return s, nil
+		}
+	}
+}
+
+// registered returns the type of every module section decoded so far
+func registered() map[string]reflect.Type {
+	sectionsMu.Lock()
+	defer sectionsMu.Unlock()
+
+	types := make(map[string]reflect.Type, len(sections))
+	for key, defaults := range sections {
+		types[key] = reflect.TypeOf(defaults)
+	}
+	return types
+}
+
+// current returns the active snapshot
+func current() *snapshot {
+	s := active.Load()
+	if s == nil {
+		log.Fatalf("Configuration has not been initialized; aborting \n")
+	}
+	return s
+}
+
+// Get returns the typed configuration. The returned value is never modified;
+// a reload replaces it, so call Get again rather than keeping it around.
+func Get() *AppConfig {
+	return current().cfg
+}
+
+// Decode unmarshals and validates the section at key into out, e.g. a
+// module's [modules.<name>] section. Fields missing from the file keep the
+// values out held the first time key was decoded, which is how sections
+// provide defaults. The section is validated again on every reload; call
+// Decode from an OnChange listener to pick up the new values.
+func Decode(key string, out interface{}) error {
+	sectionsMu.Lock()
+	if defaults, exists := sections[key]; exists {
+		// Start from the defaults again so keys removed from the file
+		// do not keep their previous values
+		reset(out, defaults)
+	} else {
+		sections[key] = clone(out)
+	}
+	sectionsMu.Unlock()
+
+	s := current()
+	if t, bound := s.sections[key]; !bound || t != reflect.TypeOf(out) {
+		// The environment of a section is only known once it has been
+		// decoded, so the first Decode derives a snapshot applying it
+		var err error
+		if s, err = derive(); err != nil {
 			return err
 		}
-		return err
 	}
+	return decode(s.v, key, out)
+}
 
-	return nil
+// clone returns a pointer to a shallow copy of the value out points to
+func clone(out interface{}) interface{} {
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return out
+	}
+	copied := reflect.New(value.Elem().Type())
+	copied.Elem().Set(value.Elem())
+	return copied.Interface()
+}
+
+// reset copies the value defaults points to into out when both point to the
+// same type
+func reset(out, defaults interface{}) {
+	value := reflect.ValueOf(out)
+	source := reflect.ValueOf(defaults)
+	if value.Kind() != reflect.Ptr || value.IsNil() || source.Type() != value.Type() {
+		return
+	}
+	value.Elem().Set(source.Elem())
+}
+
+// SetDefault sets the value used when key is missing from the configuration.
+// It is meant to be called during startup, before the configuration is read
+// concurrently.
+func SetDefault(key string, value interface{}) {
+	defaults[key] = value
+	if active.Load() != nil {
+		if _, err := derive(); err != nil {
+			log.Printf("Configuration default %s not applied: %v \n", key, err)
+		}
+	}
 }
 
 func checkKey(key string) {
-	if !viper.IsSet(key) {
+	if !current().v.IsSet(key) {
 		log.Fatalf("Configuration key %s not found; aborting \n", key)
 		os.Exit(1)
 	}
@@ -49,15 +239,25 @@ func checkKey(key string) {
 
 func GetString(key string) string {
 	checkKey(key)
-	return viper.GetString(key)
+	return current().v.GetString(key)
 }
 
 func GetInt(key string) int {
 	checkKey(key)
-	return viper.GetInt(key)
+	return current().v.GetInt(key)
 }
 
 func GetBool(key string) bool {
 	checkKey(key)
-	return viper.GetBool(key)
+	return current().v.GetBool(key)
+}
+
+func GetStringSlice(key string) []string {
+	checkKey(key)
+	return current().v.GetStringSlice(key)
+}
+
+func GetDuration(key string) time.Duration {
+	checkKey(key)
+	return current().v.GetDuration(key)
 }
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const validConfig = `
+[server]
+app_name = "test"
+port = "8080"
+http_timeout = "15s"
+
+[database]
+db_driver = "sqlite"
+db_name = ":memory:"
+
+[jwt]
+signature_key = "secret"
+
+[modules.billing]
+currency = "EUR"
+`
+
+// load writes content to a temporary config file and initializes it
+func load(t *testing.T, content string) error {
+	t.Helper()
+
+	cfg := NewConfig(write(t, filepath.Join(t.TempDir(), "config.toml"), content))
+	return cfg.Initialize()
+}
+
+// write replaces the content of file
+func write(t *testing.T, file, content string) string {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestInitializeAppliesDefaults(t *testing.T) {
+	if err := load(t, validConfig); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg := Get()
+	if cfg.Server.HTTPTimeout != 15*time.Second {
+		t.Errorf("expected 15s http timeout, got %s", cfg.Server.HTTPTimeout)
+	}
+	if cfg.Server.ShutdownTimeout != 30*time.Second {
+		t.Errorf("expected default 30s shutdown timeout, got %s", cfg.Server.ShutdownTimeout)
+	}
+	if cfg.Pool.ConnLifetime != time.Hour {
+		t.Errorf("expected default 1h conn lifetime, got %s", cfg.Pool.ConnLifetime)
+	}
+	if got := GetDuration("server.shutdown_timeout"); got != 30*time.Second {
+		t.Errorf("GetDuration returned %s", got)
+	}
+}
+
+func TestInitializeReportsEveryProblem(t *testing.T) {
+	err := load(t, `
+[server]
+port = "http"
+http_timeout = 60
+
+[server.tls]
+enabled = true
+client_auth = "require_and_verify"
+
+[database]
+db_driver = "oracle"
+db_name = "app"
+
+[jwt]
+access_ttl = "0s"
+`)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	message := err.Error()
+	for _, want := range []string{
+		"server.app_name is required",
+		"server.port must be numeric",
+		"'server.http_timeout'",
+		"server.tls.cert_file is required when TLS is enabled",
+		"server.tls.client_ca_file is required",
+		"database.db_driver must be one of",
+		"database.db_host is required",
+		"jwt.access_ttl must be greater than 0",
+		"jwt.signature_key is required",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("error does not mention %q:\n%s", want, message)
+		}
+	}
+
+	if strings.Count(message, "server.http_timeout") != 1 {
+		t.Errorf("http_timeout should be reported once:\n%s", message)
+	}
+}
+
+func TestInitializeRejectsClientCAWithoutVerification(t *testing.T) {
+	err := load(t, validConfig+`
+[server.tls]
+enabled = true
+cert_file = "server.crt"
+key_file = "server.key"
+client_ca_file = "clients-ca.pem"
+`)
+	if err == nil || !strings.Contains(err.Error(), "server.tls.client_auth must be verify_if_given or require_and_verify") {
+		t.Errorf("expected client_auth to be rejected, got %v", err)
+	}
+}
+
+func TestDecodeModuleSection(t *testing.T) {
+	if err := load(t, validConfig); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type billingConfig struct {
+		Currency string        `mapstructure:"currency" validate:"required,len=3"`
+		Grace    time.Duration `mapstructure:"grace" validate:"gt=0"`
+	}
+
+	cfg := billingConfig{Grace: time.Hour}
+	if err := Decode("modules.billing", &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Currency != "EUR" || cfg.Grace != time.Hour {
+		t.Errorf("unexpected section %+v", cfg)
+	}
+
+	missing := billingConfig{}
+	err := Decode("modules.shipping", &missing)
+	if err == nil || !strings.Contains(err.Error(), "modules.shipping.currency is required") {
+		t.Errorf("expected a required error for the missing section, got %v", err)
+	}
+}
+
+func TestEnvironmentOverrides(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "db_password")
+	if err := os.WriteFile(secret, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("APP_SERVER_PORT", "9090")
+	t.Setenv("APP_SERVER_SHUTDOWN_TIMEOUT", "5s")
+	t.Setenv("APP_DATABASE_DB_PASSWORD_FILE", secret)
+	t.Setenv("APP_JWT_SIGNATURE_KEY", "from-env")
+	t.Setenv("APP_MODULES_SHIPPING_CARRIER", "dhl")
+
+	if err := load(t, validConfig); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg := Get()
+	if cfg.Server.Port != "9090" {
+		t.Errorf("expected port from environment, got %s", cfg.Server.Port)
+	}
+	if cfg.Server.ShutdownTimeout != 5*time.Second {
+		t.Errorf("expected shutdown timeout from environment, got %s", cfg.Server.ShutdownTimeout)
+	}
+	if cfg.Database.Password != "from-file" {
+		t.Errorf("expected password from file, got %q", cfg.Database.Password)
+	}
+	if cfg.JWT.SignatureKey != "from-env" {
+		t.Errorf("expected signature key from environment, got %q", cfg.JWT.SignatureKey)
+	}
+
+	// A module section that only exists in the environment
+	var shipping struct {
+		Carrier string `mapstructure:"carrier" validate:"required"`
+	}
+	if err := Decode("modules.shipping", &shipping); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if shipping.Carrier != "dhl" {
+		t.Errorf("expected carrier from environment, got %q", shipping.Carrier)
+	}
+}
+
+func TestSecretFileConflicts(t *testing.T) {
+	t.Setenv("APP_DATABASE_DB_PASSWORD", "plain")
+	t.Setenv("APP_DATABASE_DB_PASSWORD_FILE", "/does/not/matter")
+
+	err := load(t, validConfig)
+	if err == nil || !strings.Contains(err.Error(), "APP_DATABASE_DB_PASSWORD and APP_DATABASE_DB_PASSWORD_FILE are both set") {
+		t.Errorf("expected a conflict error, got %v", err)
+	}
+}
+
+func TestDecodeDoesNotModifyActiveSnapshot(t *testing.T) {
+	t.Setenv("APP_MODULES_SHIPPING_CARRIER", "dhl")
+	if err := load(t, validConfig); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	before := current()
+
+	var shipping struct {
+		Carrier string `mapstructure:"carrier"`
+	}
+	if err := Decode("modules.shipping", &shipping); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if shipping.Carrier != "dhl" {
+		t.Errorf("expected carrier from environment, got %q", shipping.Carrier)
+	}
+	for _, key := range before.v.AllKeys() {
+		if key == "modules.shipping.carrier" {
+			t.Error("expected the previous snapshot to be left untouched")
+		}
+	}
+	if Get() != before.cfg {
+		t.Error("expected the typed configuration to be kept")
+	}
+
+	// Sections decoded before are applied without deriving a snapshot
+	after := current()
+	if err := Decode("modules.shipping", &shipping); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if current() != after {
+		t.Error("expected the active snapshot to be reused")
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix is the prefix of environment variables overriding configuration
+// keys: APP_DATABASE_DB_PASSWORD overrides database.db_password
+const EnvPrefix = "APP"
+
+// FileSuffix marks environment variables that point to a file holding the
+// value, e.g. APP_DATABASE_DB_PASSWORD_FILE=/run/secrets/db_password
+const FileSuffix = "_FILE"
+
+// EnvName returns the environment variable that overrides key
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// setupEnv enables environment overrides on v
+func setupEnv(v *viper.Viper) {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+}
+
+// bindEnvs registers every key of the struct type t under prefix, so that
+// keys absent from the file can still be set from the environment
+func bindEnvs(v *viper.Viper, prefix string, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.SplitN(field.Tag.Get("mapstructure"), ",", 2)[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Struct && fieldType != reflect.TypeOf(time.Time{}) {
+			bindEnvs(v, key, fieldType)
+			continue
+		}
+		// Lists of tables such as database.replicas cannot be addressed
+		// key by key
+		if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct {
+			continue
+		}
+
+		v.BindEnv(key)
+	}
+}
+
+// applySecretFiles sets every known key whose <ENV>_FILE variable is set
+// to the content of that file, with trailing newlines removed
+func applySecretFiles(v *viper.Viper) error {
+	var problems []string
+	for _, key := range v.AllKeys() {
+		name := EnvName(key)
+		path, ok := os.LookupEnv(name + FileSuffix)
+		if !ok {
+			continue
+		}
+
+		if _, set := os.LookupEnv(name); set {
+			problems = append(problems, fmt.Sprintf("%s and %s are both set", name, name+FileSuffix))
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name+FileSuffix, err))
+			continue
+		}
+
+		v.Set(key, strings.TrimRight(string(content), "\r\n"))
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
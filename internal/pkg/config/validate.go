@@ -0,0 +1,255 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+// ValidationError lists every problem found in the configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration:\n  - %s", strings.Join(e.Problems, "\n  - "))
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// Report fields by their configuration key rather than the Go field name
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("mapstructure"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+
+	v.RegisterStructValidation(validateTLS, TLSConfig{})
+	v.RegisterStructValidation(validateDatabase, DatabaseConfig{})
+	v.RegisterStructValidation(validateJWT, JWTConfig{})
+
+	return v
+}
+
+func validateTLS(sl validator.StructLevel) {
+	tls := sl.Current().Interface().(TLSConfig)
+	if !tls.Enabled {
+		return
+	}
+	if tls.CertFile == "" {
+		sl.ReportError(tls.CertFile, "cert_file", "CertFile", "required_with_tls", "")
+	}
+	if tls.KeyFile == "" {
+		sl.ReportError(tls.KeyFile, "key_file", "KeyFile", "required_with_tls", "")
+	}
+	if (tls.ClientAuth == "verify_if_given" || tls.ClientAuth == "require_and_verify") && tls.ClientCAFile == "" {
+		sl.ReportError(tls.ClientCAFile, "client_ca_file", "ClientCAFile", "required_with_client_verify", "")
+	}
+	if tls.ClientCAFile != "" && tls.ClientAuth != "verify_if_given" && tls.ClientAuth != "require_and_verify" {
+		sl.ReportError(tls.ClientAuth, "client_auth", "ClientAuth", "client_verify_with_ca", "")
+	}
+}
+
+func validateDatabase(sl validator.StructLevel) {
+	db := sl.Current().Interface().(DatabaseConfig)
+	if db.Driver == "sqlite" {
+		if len(db.Replicas) > 0 {
+			sl.ReportError(db.Replicas, "replicas", "Replicas", "unsupported_with_sqlite", "")
+		}
+		return
+	}
+	if db.Host == "" {
+		sl.ReportError(db.Host, "db_host", "Host", "required", "")
+	}
+	if db.Port == "" {
+		sl.ReportError(db.Port, "db_port", "Port", "required", "")
+	}
+	if db.Username == "" {
+		sl.ReportError(db.Username, "db_username", "Username", "required", "")
+	}
+}
+
+func validateJWT(sl validator.StructLevel) {
+	jwt := sl.Current().Interface().(JWTConfig)
+	if len(jwt.Keys) == 0 {
+		if jwt.SignatureKey == "" {
+			sl.ReportError(jwt.SignatureKey, "signature_key", "SignatureKey", "required", "")
+		}
+		return
+	}
+
+	for _, key := range jwt.Keys {
+		if key.ID == jwt.SigningKey {
+			if key.PrivateKeyFile == "" {
+				sl.ReportError(jwt.SigningKey, "signing_key", "SigningKey", "signing_key_private", "")
+			}
+			return
+		}
+	}
+	sl.ReportError(jwt.SigningKey, "signing_key", "SigningKey", "signing_key_unknown", "")
+}
+
+// describe turns a validation failure into a readable sentence
+func describe(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_with_tls":
+		return "is required when TLS is enabled"
+	case "required_with_client_verify":
+		return "is required to verify client certificates"
+	case "client_verify_with_ca":
+		return fmt.Sprintf("must be verify_if_given or require_and_verify when client_ca_file is set, got %q", fmt.Sprint(fe.Value()))
+	case "signing_key_private":
+		return fmt.Sprintf("must name a key with a private_key_file, %q has none", fmt.Sprint(fe.Value()))
+	case "signing_key_unknown":
+		return fmt.Sprintf("must be the id of one of jwt.keys, got %q", fmt.Sprint(fe.Value()))
+	case "unsupported_with_sqlite":
+		return "are not supported with the sqlite driver"
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s], got %q", fe.Param(), fmt.Sprint(fe.Value()))
+	case "numeric":
+		return fmt.Sprintf("must be numeric, got %q", fmt.Sprint(fe.Value()))
+	case "gt", "gte", "lt", "lte", "min", "max":
+		if d, ok := fe.Value().(time.Duration); ok {
+			return fmt.Sprintf("must be %s %s, got %s", comparisons[fe.Tag()], fe.Param(), d)
+		}
+		return fmt.Sprintf("must be %s %s, got %v", comparisons[fe.Tag()], fe.Param(), fe.Value())
+	default:
+		return fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
+}
+
+var comparisons = map[string]string{
+	"gt":  "greater than",
+	"gte": "at least",
+	"min": "at least",
+	"lt":  "less than",
+	"lte": "at most",
+	"max": "at most",
+}
+
+// durationHook decodes "30s"-style strings into time.Duration and rejects
+// bare numbers, which would otherwise silently be read as nanoseconds
+func durationHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+
+	switch value := data.(type) {
+	case string:
+		return time.ParseDuration(value)
+	case time.Duration:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("expected a duration like \"30s\" or \"5m\", got %v", data)
+	}
+}
+
+// decode unmarshals the value at key (the whole configuration when key is
+// empty) into out and validates it. Every decoding and validation problem
+// is reported in a single *ValidationError.
+func decode(v *viper.Viper, key string, out interface{}) error {
+	// Resolve the section from all settings rather than v.UnmarshalKey so
+	// keys that only exist as environment variables are included
+	var input interface{} = v.AllSettings()
+	if key != "" {
+		for _, part := range strings.Split(key, ".") {
+			section, ok := input.(map[string]interface{})
+			if !ok {
+				input = nil
+				break
+			}
+			input = section[part]
+		}
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			durationHook,
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	if input != nil {
+		err = decoder.Decode(input)
+	}
+
+	var problems []string
+	if err != nil {
+		problems = append(problems, flatten(err)...)
+	}
+
+	if err := validate.Struct(out); err != nil {
+		var fieldErrors validator.ValidationErrors
+		if !errors.As(err, &fieldErrors) {
+			return err
+		}
+		for _, fe := range fieldErrors {
+			name := fieldKey(key, fe.Namespace())
+			if mentioned(problems, name) {
+				// Already reported as a decoding problem
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s %s", name, describe(fe)))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// fieldKey converts a validator namespace such as AppConfig.server.port into
+// the configuration key server.port
+func fieldKey(prefix, namespace string) string {
+	parts := strings.SplitN(namespace, ".", 2)
+	name := parts[len(parts)-1]
+	if prefix != "" {
+		name = prefix + "." + name
+	}
+	return name
+}
+
+func mentioned(problems []string, key string) bool {
+	for _, problem := range problems {
+		if strings.Contains(problem, "'"+key+"'") {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten splits joined decoding errors into one message per field
+func flatten(err error) []string {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var messages []string
+		for _, e := range joined.Unwrap() {
+			messages = append(messages, flatten(e)...)
+		}
+		return messages
+	}
+	// mapstructure wraps the joined field errors in a summary message
+	if inner := errors.Unwrap(err); inner != nil {
+		if _, ok := inner.(interface{ Unwrap() []error }); ok {
+			return flatten(inner)
+		}
+	}
+	return []string{err.Error()}
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/filewatch"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"sync"
+	"time"
+)
+
+// Change describes a successful reload of the configuration file
+type Change struct {
+	Old *AppConfig
+	New *AppConfig
+}
+
+// ChangedEvent is the event bus type the application publishes with a Change
+// payload after the configuration has been reloaded
+const ChangedEvent = "config.changed"
+
+// debounce groups the burst of events editors produce when saving a file
+const debounce = 100 * time.Millisecond
+
+var (
+	listenersMu sync.RWMutex
+	listeners   []func(Change)
+
+	watchMu sync.Mutex
+	watcher *filewatch.Watcher
+	done    chan struct{}
+
+	// reloadMu serializes reloads started by the watcher and by Reload
+	reloadMu sync.Mutex
+)
+
+// OnChange registers fn to be called after the configuration has been
+// reloaded. Listeners run in registration order on the watcher goroutine;
+// module sections are read again with Decode.
+func OnChange(fn func(Change)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// Reload reads the configuration file again. The new configuration and every
+// module section decoded so far are validated before anything is replaced;
+// if any of them is invalid the current configuration is kept and the
+// problems are returned.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	old := current()
+
+	s, err := loadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	sectionsMu.Lock()
+	var errs []error
+	for key, defaults := range sections {
+		if err := decode(s.v, key, clone(defaults)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	sectionsMu.Unlock()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	active.Store(s)
+
+	listenersMu.RLock()
+	defer listenersMu.RUnlock()
+	for _, fn := range listeners {
+		fn(Change{Old: old.cfg, New: s.cfg})
+	}
+
+	return nil
+}
+
+// Watch reloads the configuration whenever the file loaded by Initialize
+// changes, until StopWatching is called. Reloads and their failures are
+// logged to log.
+func Watch(log *logger.Logger) error {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+
+	if watcher != nil {
+		return nil
+	}
+	if filename == "" {
+		return errors.New("configuration has not been initialized")
+	}
+
+	w, err := filewatch.New(filename)
+	if err != nil {
+		return fmt.Errorf("watching configuration: %w", err)
+	}
+
+	watcher = w
+	done = make(chan struct{})
+	go watch(w, done, log)
+
+	return nil
+}
+
+// StopWatching stops the watcher started by Watch
+func StopWatching() error {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+
+	if watcher == nil {
+		return nil
+	}
+	close(done)
+	err := watcher.Close()
+	watcher = nil
+	return err
+}
+
+func watch(w *filewatch.Watcher, done chan struct{}, log *logger.Logger) {
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if w.Relevant(event) {
+				timer.Reset(debounce)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Error("Configuration watcher error", "error", err)
+		case <-timer.C:
+			if err := Reload(); err != nil {
+				log.Error("Keeping current configuration", "file", filename, "error", err)
+				continue
+			}
+			log.Info("Configuration reloaded", "file", filename)
+		}
+	}
+}
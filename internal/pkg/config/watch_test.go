@@ -0,0 +1,78 @@
+package config
+
+import (
+	"go-modular-boilerplate/internal/pkg/logger"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadKeepsConfigurationWhenInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.toml")
+	cfg := NewConfig(write(t, file, validConfig))
+	if err := cfg.Initialize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var billing struct {
+		Currency string `mapstructure:"currency" validate:"required,len=3"`
+	}
+	if err := Decode("modules.billing", &billing); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// An invalid module section rejects the whole file
+	write(t, file, strings.Replace(validConfig, `currency = "EUR"`, `currency = "EURO"`, 1)+"\n[log]\nlevel = \"debug\"\n")
+	err := Reload()
+	if err == nil || !strings.Contains(err.Error(), "modules.billing.currency") {
+		t.Fatalf("expected the module section to be rejected, got %v", err)
+	}
+	if Get().Log.Level != "info" {
+		t.Errorf("expected the previous log level to be kept, got %s", Get().Log.Level)
+	}
+
+	write(t, file, validConfig+"\n[log]\nlevel = \"debug\"\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if Get().Log.Level != "debug" {
+		t.Errorf("expected the new log level, got %s", Get().Log.Level)
+	}
+}
+
+func TestWatchNotifiesListeners(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.toml")
+	cfg := NewConfig(write(t, file, validConfig))
+	if err := cfg.Initialize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	changes := make(chan Change, 1)
+	OnChange(func(change Change) {
+		select {
+		case changes <- change:
+		default:
+		}
+	})
+
+	log, err := logger.NewLogger(logger.Config{Level: logger.ErrorLevel, OutputPath: filepath.Join(t.TempDir(), "test.log")}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Watch(log); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { StopWatching() })
+
+	write(t, file, strings.Replace(validConfig, `http_timeout = "15s"`, `http_timeout = "20s"`, 1))
+
+	select {
+	case change := <-changes:
+		if change.Old.Server.HTTPTimeout != 15*time.Second || change.New.Server.HTTPTimeout != 20*time.Second {
+			t.Errorf("unexpected change %s -> %s", change.Old.Server.HTTPTimeout, change.New.Server.HTTPTimeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("configuration change was not noticed")
+	}
+}
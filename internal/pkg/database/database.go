@@ -1,11 +1,11 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
-	"log"
-	"os"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -15,22 +15,34 @@ var (
 	DB             *gorm.DB
 	POSGRES_CONFIG = "user=%s password=%s dbname=%s host=%s port=%s sslmode=%s"
 	MYSQL_CONFIG   = "%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local"
+	SQLITE_CONFIG  = "%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+	// SQLITE_MEMORY is the db_name that selects an in-memory SQLite database
+	SQLITE_MEMORY = ":memory:"
 )
 
 type DBModel struct {
-	ServerMode   string `config:"server_mode"`
-	Driver       string `config:"db_driver"`
-	Host         string `config:"db_host"`
-	Port         string `config:"db_port"`
-	Name         string `config:"db_name"`
-	Username     string `config:"db_username"`
-	Password     string `config:"db_password"`
-	MaxIdleConn  int    `config:"conn_idle"`
-	MaxOpenConn  int    `config:"conn_max"`
-	ConnLifeTime int    `config:"conn_lifetime"`
+	ServerMode   string        `config:"server_mode"`
+	Driver       string        `config:"db_driver"`
+	Host         string        `config:"db_host"`
+	Port         string        `config:"db_port"`
+	Name         string        `config:"db_name"`
+	Username     string        `config:"db_username"`
+	Password     string        `config:"db_password"`
+	MaxIdleConn  int           `config:"conn_idle"`
+	MaxOpenConn  int           `config:"conn_max"`
+	ConnLifeTime time.Duration `config:"conn_lifetime"`
+
+	// Replicas serve reads, the primary above serves writes and transactions
+	Replicas      []ReplicaModel `config:"replicas"`
+	ReplicaPolicy string         `config:"replica_policy"`
+
+	replicaPools []*sql.DB
 }
 
-func (c *DBModel) OpenDB() (*gorm.DB, *error) {
+// OpenDB opens the primary connection pool, and the replicas if any are
+// configured. Errors are returned for the caller to report.
+func (c *DBModel) OpenDB() (*gorm.DB, error) {
 
 	var connection gorm.Dialector
 
@@ -41,21 +53,27 @@ func (c *DBModel) OpenDB() (*gorm.DB, *error) {
 	case "mysql":
 		connectionUrl := fmt.Sprintf(MYSQL_CONFIG, c.Username, c.Password, c.Host, c.Port, c.Name)
 		connection = mysql.Open(connectionUrl)
+	case "sqlite":
+		// Pure-Go driver, db_name is the database file path or :memory:
+		if c.Name == SQLITE_MEMORY {
+			connection = sqlite.Open(":memory:?_pragma=foreign_keys(1)")
+		} else {
+			connection = sqlite.Open(fmt.Sprintf(SQLITE_CONFIG, c.Name))
+		}
 	default:
-		log.Fatal("No Database Selected!, Please check config.toml")
-		os.Exit(1)
+		return nil, fmt.Errorf("unsupported database driver %q, please check config.toml", c.Driver)
 	}
 
-	db, err := gorm.Open(connection, &gorm.Config{})
+	// TranslateError turns the drivers' constraint violations into
+	// gorm.ErrDuplicatedKey and gorm.ErrForeignKeyViolated
+	db, err := gorm.Open(connection, &gorm.Config{TranslateError: true})
 	if err != nil {
-		log.Fatalf("Cannot Connect to DB With Message %s", err.Error())
-		return nil, &err
+		return nil, fmt.Errorf("cannot connect to database: %w", err)
 	}
 
 	conPool, err := db.DB()
 	if err != nil {
-		log.Fatalf("Cannot Create Connection Pool to DB With Message %s", err.Error())
-		return nil, &err
+		return nil, fmt.Errorf("cannot create database connection pool: %w", err)
 	}
 
 	/** SetMaxIdleConns sets the maximum number of connections in the idle connection pool.
@@ -68,7 +86,24 @@ func (c *DBModel) OpenDB() (*gorm.DB, *error) {
 
 	/** SetConnMaxLifetime sets the maximum amount of time a connection may be reused.
 	**/
-	conPool.SetConnMaxLifetime(time.Duration(c.ConnLifeTime) * time.Minute)
+	conPool.SetConnMaxLifetime(c.ConnLifeTime)
+
+	/** Every connection to :memory: opens a new empty database, so keep
+	exactly one connection open for the lifetime of the pool
+	**/
+	if c.Driver == "sqlite" && c.Name == SQLITE_MEMORY {
+		conPool.SetMaxOpenConns(1)
+		conPool.SetMaxIdleConns(1)
+		conPool.SetConnMaxLifetime(0)
+		conPool.SetConnMaxIdleTime(0)
+	}
+
+	if len(c.Replicas) > 0 {
+		if err := c.registerReplicas(db); err != nil {
+			c.Close(db)
+			return nil, err
+		}
+	}
 
 	return db, nil
 }
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"errors"
+	"go-modular-boilerplate/internal/pkg/errs"
+
+	"gorm.io/gorm"
+)
+
+// Domain errors returned by TranslateError
+var (
+	ErrNotFound           = errs.New(errs.NotFound, "not_found", "record not found")
+	ErrDuplicatedKey      = errs.New(errs.Conflict, "duplicated_key", "record already exists")
+	ErrForeignKeyViolated = errs.New(errs.Conflict, "foreign_key_violated", "record is still referenced")
+)
+
+// TranslateError turns GORM and driver errors into domain errors, so
+// repositories report missing rows and constraint violations the same way
+// on every database. Other errors are returned unchanged.
+func TranslateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound.Wrap(err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicatedKey.Wrap(err)
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return ErrForeignKeyViolated.Wrap(err)
+	}
+	return err
+}
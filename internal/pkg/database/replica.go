@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// Replica policies
+const (
+	REPLICA_POLICY_RANDOM      = "random"
+	REPLICA_POLICY_ROUND_ROBIN = "round_robin"
+)
+
+// ReplicaModel describes a read replica. Empty credentials and database
+// name fall back to the primary's; each replica has its own pool settings.
+type ReplicaModel struct {
+	Host         string        `config:"db_host"`
+	Port         string        `config:"db_port"`
+	Name         string        `config:"db_name"`
+	Username     string        `config:"db_username"`
+	Password     string        `config:"db_password"`
+	MaxIdleConn  int           `config:"conn_idle"`
+	MaxOpenConn  int           `config:"conn_max"`
+	ConnLifeTime time.Duration `config:"conn_lifetime"`
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a context whose queries made through Conn go to the
+// primary, for reads that must see the caller's own writes
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Conn returns the shared DB bound to ctx. Reads go to a replica unless ctx
+// was created with WithPrimary; writes and transactions always go to the
+// primary. Inside Transaction it returns the transaction.
+func Conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+
+	db := DB.WithContext(ctx)
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		db = db.Clauses(dbresolver.Write)
+	}
+	return db
+}
+
+// Primary returns a reusable session of db whose reads also go to the
+// primary, e.g. for migrations
+func Primary(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+// registerReplicas routes reads of db to the configured replicas
+func (c *DBModel) registerReplicas(db *gorm.DB) error {
+	var policy dbresolver.Policy
+	switch c.ReplicaPolicy {
+	case "", REPLICA_POLICY_RANDOM:
+		policy = dbresolver.RandomPolicy{}
+	case REPLICA_POLICY_ROUND_ROBIN:
+		policy = dbresolver.StrictRoundRobinPolicy()
+	default:
+		return fmt.Errorf("unsupported replica policy %q", c.ReplicaPolicy)
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(c.Replicas))
+	for i, replica := range c.Replicas {
+		conn, err := c.openReplica(replica)
+		if err != nil {
+			return fmt.Errorf("replica %d (%s): %w", i, replica.Host, err)
+		}
+		c.replicaPools = append(c.replicaPools, conn)
+
+		switch c.Driver {
+		case "postgres":
+			replicas = append(replicas, postgres.New(postgres.Config{Conn: conn}))
+		case "mysql":
+			replicas = append(replicas, mysql.New(mysql.Config{Conn: conn}))
+		}
+	}
+
+	return db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   policy,
+	}))
+}
+
+// openReplica opens the connection pool of a single replica
+func (c *DBModel) openReplica(replica ReplicaModel) (*sql.DB, error) {
+	name := replica.Name
+	if name == "" {
+		name = c.Name
+	}
+	username := replica.Username
+	if username == "" {
+		username = c.Username
+	}
+	password := replica.Password
+	if password == "" {
+		password = c.Password
+	}
+	port := replica.Port
+	if port == "" {
+		port = c.Port
+	}
+
+	var (
+		conn *sql.DB
+		err  error
+	)
+	switch c.Driver {
+	case "postgres":
+		conn, err = sql.Open("pgx", fmt.Sprintf(POSGRES_CONFIG, username, password, name, replica.Host, port, "disable"))
+	case "mysql":
+		conn, err = sql.Open("mysql", fmt.Sprintf(MYSQL_CONFIG, username, password, replica.Host, port, name))
+	default:
+		return nil, fmt.Errorf("read replicas are not supported for driver %q", c.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetMaxIdleConns(replica.MaxIdleConn)
+	conn.SetMaxOpenConns(replica.MaxOpenConn)
+	conn.SetConnMaxLifetime(replica.ConnLifeTime)
+
+	return conn, nil
+}
+
+// Close closes the primary and replica connection pools
+func (c *DBModel) Close(db *gorm.DB) error {
+	var errs []error
+
+	if db != nil {
+		conPool, err := db.DB()
+		if err != nil {
+			errs = append(errs, err)
+		} else if err := conPool.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, conn := range c.replicaPools {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	c.replicaPools = nil
+
+	return errors.Join(errs...)
+}
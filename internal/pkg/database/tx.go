@@ -0,0 +1,18 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// Transaction runs fn in a transaction on the primary. Queries made through
+// Conn with the context passed to fn are part of the transaction, so
+// repositories do not need to know about it. Nested calls use savepoints.
+func Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return Conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// ErrStaleVersion is returned when a versioned record was changed or deleted
+// by another request since it was read
+var ErrStaleVersion = errs.New(errs.Conflict, "stale_version", "record was modified by another request")
+
+// Versioned is embedded in entities updated with optimistic concurrency
+// control. UpdateVersioned and DeleteVersioned only write a row whose version
+// is still the one the entity was read at, and every update increments it,
+// so of two requests that read the same version only the first one wins.
+type Versioned struct {
+	Version uint `gorm:"not null;default:1" json:"-"`
+}
+
+// CurrentVersion returns the version the entity was read or last written at
+func (v *Versioned) CurrentVersion() uint {
+	return v.Version
+}
+
+func (v *Versioned) setVersion(version uint) {
+	v.Version = version
+}
+
+// VersionedEntity is implemented by pointers to entities embedding Versioned
+type VersionedEntity interface {
+	CurrentVersion() uint
+	setVersion(version uint)
+}
+
+// UpdateVersioned sets the given columns, and updated_at, of the row of
+// entity if its version has not changed, and increments the version. The
+// entity's primary key must be set. It returns ErrStaleVersion when the row
+// was changed or deleted since the entity was read.
+func UpdateVersioned(ctx context.Context, entity VersionedEntity, columns map[string]interface{}) error {
+	db := Conn(ctx)
+	if err := checkPrimaryKey(db, entity); err != nil {
+		return err
+	}
+
+	version := entity.CurrentVersion()
+	updates := make(map[string]interface{}, len(columns)+1)
+	for column, value := range columns {
+		updates[column] = value
+	}
+	updates["version"] = gorm.Expr("version + 1")
+
+	result := db.Model(entity).Where("version = ?", version).Updates(updates)
+	if result.Error != nil {
+		return TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleVersion
+	}
+	entity.setVersion(version + 1)
+	return nil
+}
+
+// DeleteVersioned deletes the row of entity if its version has not changed.
+// The entity's primary key must be set. It returns ErrStaleVersion when the
+// row was changed or deleted since the entity was read.
+func DeleteVersioned(ctx context.Context, entity VersionedEntity) error {
+	db := Conn(ctx)
+	if err := checkPrimaryKey(db, entity); err != nil {
+		return err
+	}
+
+	result := db.Where("version = ?", entity.CurrentVersion()).Delete(entity)
+	if result.Error != nil {
+		return TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleVersion
+	}
+	return nil
+}
+
+// checkPrimaryKey guards against writing every row with the entity's version
+// when the primary key is missing
+func checkPrimaryKey(db *gorm.DB, entity interface{}) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(entity); err != nil {
+		return err
+	}
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return errors.New("versioned entity has no primary key")
+	}
+	if _, zero := field.ValueOf(db.Statement.Context, reflect.ValueOf(entity).Elem()); zero {
+		return errors.New("versioned entity has no primary key value")
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package errs
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind classifies domain errors independently of the module and the
+// storage they come from. A Kind is itself an error, so errors.Is(err,
+// errs.NotFound) reports whether err is of that kind.
+type Kind string
+
+// Kinds
+const (
+	NotFound     Kind = "not_found"
+	Conflict     Kind = "conflict"
+	Validation   Kind = "validation"
+	Forbidden    Kind = "forbidden"
+	Unauthorized Kind = "unauthorized"
+	// PreconditionFailed is the kind of requests made on a stale version
+	// of a resource, see the etag package
+	PreconditionFailed Kind = "precondition_failed"
+)
+
+func (k Kind) Error() string {
+	return string(k)
+}
+
+// Error is a domain error: a kind, a stable code clients can rely on, and a
+// message safe to show them. The cause, if any, is kept for logging.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+}
+
+// New creates a domain error, typically assigned to a package-level
+// variable:
+//
+//	var ErrUserNotFound = errs.New(errs.NotFound, "user_not_found", "user not found")
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Wrap returns a copy of e caused by err. The copy still matches e with
+// errors.Is.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the error's kind, so a module's errors can be checked against
+// the kinds as well as against themselves, and errors with the same kind
+// and code
+func (e *Error) Is(target error) bool {
+	switch target := target.(type) {
+	case Kind:
+		return target == e.Kind
+	case *Error:
+		return target.Code != "" && target.Code == e.Code && target.Kind == e.Kind
+	}
+	return false
+}
+
+// ErrorKind implements Kinded.
+func (e *Error) ErrorKind() Kind {
+	return e.Kind
+}
+
+// Kinded is implemented by errors of other packages that belong to a kind,
+// e.g. the validation errors of a parser
+type Kinded interface {
+	ErrorKind() Kind
+}
+
+// KindOf returns the kind of the first error in err's chain that has one,
+// or "" for errors that are not domain errors
+func KindOf(err error) Kind {
+	var kinded Kinded
+	if errors.As(err, &kinded) {
+		return kinded.ErrorKind()
+	}
+	var kind Kind
+	if errors.As(err, &kind) {
+		return kind
+	}
+	return ""
+}
+
+// CodeOf returns the code of the first *Error in err's chain, or the kind
+// when it has none
+func CodeOf(err error) string {
+	var domain *Error
+	if errors.As(err, &domain) && domain.Code != "" {
+		return domain.Code
+	}
+	if kind := KindOf(err); kind != "" {
+		return string(kind)
+	}
+	return "internal"
+}
+
+// statuses maps the kinds to HTTP statuses
+var statuses = map[Kind]int{
+	NotFound:           http.StatusNotFound,
+	Conflict:           http.StatusConflict,
+	Validation:         http.StatusBadRequest,
+	Forbidden:          http.StatusForbidden,
+	Unauthorized:       http.StatusUnauthorized,
+	PreconditionFailed: http.StatusPreconditionFailed,
+}
+
+// HTTPStatus returns the HTTP status of err, 500 for errors that are not
+// domain errors
+func HTTPStatus(err error) int {
+	if status, ok := statuses[KindOf(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// FieldError is a problem with one field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Fielded is implemented by errors reporting problems with the fields of a
+// request, e.g. validation errors
+type Fielded interface {
+	FieldErrors() []FieldError
+}
+
+// FieldsOf returns the field errors of the first error in err's chain that
+// has them
+func FieldsOf(err error) []FieldError {
+	var fielded Fielded
+	if errors.As(err, &fielded) {
+		return fielded.FieldErrors()
+	}
+	return nil
+}
+
+// Localized is implemented by errors whose messages can be rendered in the
+// language of a client, e.g. validation errors
+type Localized interface {
+	Localize(acceptLanguage string) error
+}
+
+// Localize returns the first error in err's chain that can be localized in
+// the first supported language of an Accept-Language header, or err
+func Localize(err error, acceptLanguage string) error {
+	var localized Localized
+	if acceptLanguage != "" && errors.As(err, &localized) {
+		return localized.Localize(acceptLanguage)
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+var errWidgetNotFound = New(NotFound, "widget_not_found", "widget not found")
+
+type parseError struct{}
+
+func (parseError) Error() string   { return "bad input" }
+func (parseError) ErrorKind() Kind { return Validation }
+
+func TestIs(t *testing.T) {
+	cause := errors.New("record not found")
+	err := fmt.Errorf("get widget: %w", errWidgetNotFound.Wrap(cause))
+
+	if !errors.Is(err, errWidgetNotFound) {
+		t.Error("expected the wrapped error to match the original")
+	}
+	if !errors.Is(err, NotFound) {
+		t.Error("expected the error to match its kind")
+	}
+	if errors.Is(err, Conflict) {
+		t.Error("expected the error not to match another kind")
+	}
+	if errors.Is(err, New(NotFound, "gadget_not_found", "gadget not found")) {
+		t.Error("expected the error not to match another code")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected the error to keep its cause")
+	}
+	if errWidgetNotFound.Err != nil {
+		t.Error("expected Wrap to leave the original untouched")
+	}
+	if err.Error() != "get widget: widget not found" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{errWidgetNotFound, http.StatusNotFound, "widget_not_found"},
+		{fmt.Errorf("save: %w", New(Conflict, "taken", "taken")), http.StatusConflict, "taken"},
+		{parseError{}, http.StatusBadRequest, "validation"},
+		{fmt.Errorf("denied: %w", Forbidden), http.StatusForbidden, "forbidden"},
+		{New(Unauthorized, "", "who are you"), http.StatusUnauthorized, "unauthorized"},
+		{PreconditionFailed, http.StatusPreconditionFailed, "precondition_failed"},
+		{errors.New("connection refused"), http.StatusInternalServerError, "internal"},
+	}
+
+	for _, test := range tests {
+		if status := HTTPStatus(test.err); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.err, test.status, status)
+		}
+		if code := CodeOf(test.err); code != test.code {
+			t.Errorf("%v: expected code %q, got %q", test.err, test.code, code)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package etag
+
+import (
+	"go-modular-boilerplate/internal/pkg/errs"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// Headers
+const (
+	HeaderETag        = "ETag"
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+// ErrPreconditionFailed is returned when the If-Match header of a request
+// does not match the current ETag of the resource
+var ErrPreconditionFailed = errs.New(errs.PreconditionFailed, "precondition_failed", "resource has been modified")
+
+// Version returns the strong ETag of a version of a resource, such as the
+// version of an entity embedding database.Versioned
+func Version(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// Set sets the ETag header of the response
+func Set(c echo.Context, tag string) {
+	c.Response().Header().Set(HeaderETag, tag)
+}
+
+// NotModified sets the ETag header of the response and reports whether the
+// If-None-Match header of the request matches it, in which case a GET
+// handler responds with 304 Not Modified instead of the resource
+func NotModified(c echo.Context, tag string) bool {
+	Set(c, tag)
+
+	header := c.Request().Header.Get(HeaderIfNoneMatch)
+	if header == "" {
+		return false
+	}
+	// If-None-Match uses the weak comparison, RFC 9110 section 13.1.2
+	for _, candidate := range split(header) {
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckIfMatch returns ErrPreconditionFailed unless the If-Match header of
+// the request matches the current ETag of the resource. Requests without the
+// header are unconditional and pass.
+func CheckIfMatch(c echo.Context, tag string) error {
+	header := c.Request().Header.Get(HeaderIfMatch)
+	if header == "" {
+		return nil
+	}
+	// If-Match uses the strong comparison, weak tags never match, RFC 9110
+	// section 13.1.1
+	for _, candidate := range split(header) {
+		if candidate == "*" || (candidate == tag && !strings.HasPrefix(tag, "W/")) {
+			return nil
+		}
+	}
+	return ErrPreconditionFailed
+}
+
+// split returns the entity tags of a comma separated header
+func split(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
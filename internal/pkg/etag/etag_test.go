@@ -0,0 +1,68 @@
+package etag
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func context(header, value string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if value != "" {
+		req.Header.Set(header, value)
+	}
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(req, rec), rec
+}
+
+func TestNotModified(t *testing.T) {
+	tag := Version(3)
+	if tag != `"3"` {
+		t.Fatalf("unexpected tag %s", tag)
+	}
+
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"3"`, true},
+		{`W/"3"`, true},
+		{`"1", "3"`, true},
+		{"*", true},
+		{`"2"`, false},
+	}
+	for _, test := range tests {
+		c, rec := context(HeaderIfNoneMatch, test.header)
+		if got := NotModified(c, tag); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.header, test.want, got)
+		}
+		if rec.Header().Get(HeaderETag) != tag {
+			t.Errorf("%s: expected the ETag header to be set", test.header)
+		}
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	tag := Version(3)
+	tests := []struct {
+		header string
+		want   error
+	}{
+		{"", nil},
+		{`"3"`, nil},
+		{`"2", "3"`, nil},
+		{"*", nil},
+		{`W/"3"`, ErrPreconditionFailed},
+		{`"2"`, ErrPreconditionFailed},
+	}
+	for _, test := range tests {
+		c, _ := context(HeaderIfMatch, test.header)
+		if err := CheckIfMatch(c, tag); !errors.Is(err, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.header, test.want, err)
+		}
+	}
+}
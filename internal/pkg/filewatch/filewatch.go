@@ -0,0 +1,59 @@
+package filewatch
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher watches a set of files for changes. Events and Errors are those of
+// the underlying fsnotify watcher; use Relevant to filter the events.
+type Watcher struct {
+	*fsnotify.Watcher
+
+	files map[string]struct{}
+}
+
+// New starts watching files.
+//
+// It watches their directories rather than the files themselves: editors
+// and Kubernetes secret and config map mounts replace files through renames
+// and symlink swaps, which drop a watch set on the file itself.
+func New(files ...string) (*Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("creating file watcher: %w", err)
+	}
+
+	w := &Watcher{Watcher: watcher, files: make(map[string]struct{}, len(files))}
+	dirs := make(map[string]struct{})
+	for _, file := range files {
+		w.files[filepath.Clean(file)] = struct{}{}
+		dirs[filepath.Dir(file)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("watching %s: %w", dir, err)
+		}
+	}
+
+	return w, nil
+}
+
+// Relevant reports whether event may have changed one of the watched files
+func (w *Watcher) Relevant(event fsnotify.Event) bool {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+		return false
+	}
+
+	name := filepath.Clean(event.Name)
+	if _, ok := w.files[name]; ok {
+		return true
+	}
+
+	// Kubernetes updates secret and config map volumes by swapping the
+	// ..data symlink
+	return filepath.Base(name) == "..data"
+}
@@ -0,0 +1,42 @@
+package filewatch
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestRelevant(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+
+	w, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	tests := []struct {
+		event fsnotify.Event
+		want  bool
+	}{
+		{event: fsnotify.Event{Name: certFile, Op: fsnotify.Write}, want: true},
+		{event: fsnotify.Event{Name: keyFile, Op: fsnotify.Create}, want: true},
+		{event: fsnotify.Event{Name: filepath.Join(dir, "..data"), Op: fsnotify.Rename}, want: true},
+		{event: fsnotify.Event{Name: certFile, Op: fsnotify.Chmod}, want: false},
+		{event: fsnotify.Event{Name: filepath.Join(dir, "other.txt"), Op: fsnotify.Write}, want: false},
+	}
+	for _, tt := range tests {
+		if got := w.Relevant(tt.event); got != tt.want {
+			t.Errorf("Relevant(%v) = %v, want %v", tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestNewFailsOnMissingDirectory(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing", "server.crt")); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,174 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt"
+)
+
+// Claims are the registered claims of RFC 7519 along with custom claims
+type Claims struct {
+	Issuer    string    // iss
+	Subject   string    // sub
+	Audience  []string  // aud
+	ExpiresAt time.Time // exp
+	NotBefore time.Time // nbf
+	IssuedAt  time.Time // iat
+	ID        string    // jti
+
+	// Extra holds custom claims; registered claim names are ignored
+	Extra map[string]interface{}
+}
+
+// registered are the claim names held by the Claims fields
+var registered = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true,
+}
+
+// String returns the custom claim name if it is a string
+func (c *Claims) String(name string) string {
+	value, _ := c.Extra[name].(string)
+	return value
+}
+
+// HasAudience reports whether audience is one of the token's audiences
+func (c *Claims) HasAudience(audience string) bool {
+	for _, aud := range c.Audience {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// mapClaims encodes c as the payload of a token
+func (c *Claims) mapClaims() gojwt.MapClaims {
+	claims := gojwt.MapClaims{}
+	for name, value := range c.Extra {
+		if !registered[name] {
+			claims[name] = value
+		}
+	}
+
+	setString(claims, "iss", c.Issuer)
+	setString(claims, "sub", c.Subject)
+	setString(claims, "jti", c.ID)
+	setTime(claims, "exp", c.ExpiresAt)
+	setTime(claims, "nbf", c.NotBefore)
+	setTime(claims, "iat", c.IssuedAt)
+
+	switch len(c.Audience) {
+	case 0:
+	case 1:
+		claims["aud"] = c.Audience[0]
+	default:
+		claims["aud"] = c.Audience
+	}
+
+	return claims
+}
+
+func setString(claims gojwt.MapClaims, name, value string) {
+	if value != "" {
+		claims[name] = value
+	}
+}
+
+// setTime sets a NumericDate with millisecond precision, which RFC 7519
+// allows. Subject revocations compare with iat and must tell a token issued
+// right after a revocation from the ones before it.
+func setTime(claims gojwt.MapClaims, name string, value time.Time) {
+	if !value.IsZero() {
+		claims[name] = float64(value.UnixMilli()) / 1e3
+	}
+}
+
+// claimsFromMap reads the claims of a decoded token payload. A registered
+// claim of the wrong type is an error rather than being ignored.
+func claimsFromMap(raw gojwt.MapClaims) (*Claims, error) {
+	claims := &Claims{Extra: make(map[string]interface{})}
+
+	var err error
+	if claims.Issuer, err = stringClaim(raw, "iss"); err != nil {
+		return nil, err
+	}
+	if claims.Subject, err = stringClaim(raw, "sub"); err != nil {
+		return nil, err
+	}
+	if claims.ID, err = stringClaim(raw, "jti"); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt, err = timeClaim(raw, "exp"); err != nil {
+		return nil, err
+	}
+	if claims.NotBefore, err = timeClaim(raw, "nbf"); err != nil {
+		return nil, err
+	}
+	if claims.IssuedAt, err = timeClaim(raw, "iat"); err != nil {
+		return nil, err
+	}
+
+	switch aud := raw["aud"].(type) {
+	case nil:
+	case string:
+		claims.Audience = []string{aud}
+	case []interface{}:
+		for _, value := range aud {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: aud must contain strings", ErrMalformedToken)
+			}
+			claims.Audience = append(claims.Audience, s)
+		}
+	default:
+		return nil, fmt.Errorf("%w: aud must be a string or a list of strings", ErrMalformedToken)
+	}
+
+	for name, value := range raw {
+		if !registered[name] {
+			claims.Extra[name] = value
+		}
+	}
+
+	return claims, nil
+}
+
+func stringClaim(raw gojwt.MapClaims, name string) (string, error) {
+	switch value := raw[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	default:
+		return "", fmt.Errorf("%w: %s must be a string", ErrMalformedToken, name)
+	}
+}
+
+// timeClaim reads a NumericDate, the number of seconds since the epoch
+func timeClaim(raw gojwt.MapClaims, name string) (time.Time, error) {
+	var seconds float64
+	switch value := raw[name].(type) {
+	case nil:
+		return time.Time{}, nil
+	case float64:
+		seconds = value
+	case json.Number:
+		f, err := value.Float64()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %s must be a number", ErrMalformedToken, name)
+		}
+		seconds = f
+	default:
+		return time.Time{}, fmt.Errorf("%w: %s must be a number", ErrMalformedToken, name)
+	}
+
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, fmt.Errorf("%w: %s must be a number", ErrMalformedToken, name)
+	}
+	// Rounded to microseconds to drop the float64 representation error
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(math.Round(fraction*1e6))*1e3), nil
+}
@@ -1,45 +1,85 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"time"
 
 	gojwt "github.com/golang-jwt/jwt"
 )
 
+// Errors returned when a token is rejected
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("invalid token issuer")
+	ErrInvalidAudience  = errors.New("invalid token audience")
+)
+
 type JWT interface {
-	GenerateToken(data map[string]interface{}) (string, error)
+	// GenerateToken signs claims, filling in the issuer, audience, issue
+	// time, expiry and a random ID when they are not set
+	GenerateToken(claims Claims) (string, error)
+	// ValidateToken reports whether the token is valid, the error tells why
+	// it is not
 	ValidateToken(token string) (bool, error)
-	ParseToken(tokenString string) (map[string]interface{}, error)
+	// ParseToken validates the token and returns its claims
+	ParseToken(tokenString string) (*Claims, error)
 }
 
 type JWTImpl struct {
 	SignatureKey string
-	Expiration   int
+	// TTL is the lifetime of generated tokens
+	TTL time.Duration
+	// Issuer is set on generated tokens and required on validated ones
+	// when not empty
+	Issuer string
+	// Audience is set on generated tokens; validated tokens must be meant
+	// for one of them when not empty
+	Audience []string
+	// Leeway tolerates clock skew between the issuer and the validator
+	Leeway time.Duration
+	// Keys signs tokens with an asymmetric key instead of SignatureKey
+	Keys *KeySet
 }
 
-func NewJWTImpl(signatureKey string, expiration int) JWT {
-	return &JWTImpl{SignatureKey: signatureKey, Expiration: expiration}
+func NewJWTImpl(signatureKey string, ttl time.Duration) JWT {
+	return &JWTImpl{SignatureKey: signatureKey, TTL: ttl}
 }
 
-func (j *JWTImpl) GenerateToken(data map[string]interface{}) (string, error) {
-	var mySigningKey = []byte(j.SignatureKey)
-	token := gojwt.New(gojwt.SigningMethodHS256)
-	claims := token.Claims.(gojwt.MapClaims)
+func (j *JWTImpl) GenerateToken(claims Claims) (string, error) {
+	now := time.Now()
 
-	for key, value := range data {
-		claims[key] = value
+	if claims.Issuer == "" {
+		claims.Issuer = j.Issuer
+	}
+	if len(claims.Audience) == 0 {
+		claims.Audience = j.Audience
+	}
+	if claims.IssuedAt.IsZero() {
+		claims.IssuedAt = now
+	}
+	if claims.ExpiresAt.IsZero() {
+		claims.ExpiresAt = claims.IssuedAt.Add(j.TTL)
+	}
+	if claims.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return "", err
+		}
+		claims.ID = id
 	}
 
-	/**
-	-jwt expires in day-
-	 for example, if j.Expiration is 20, then the token will expire in 20 days
-	**/
-	expirationDuration := time.Duration(j.Expiration) * 24 * time.Hour * 7
-	expirationTime := time.Now().Add(expirationDuration).Unix()
-	claims["exp"] = expirationTime
+	if j.Keys != nil {
+		return j.Keys.sign(claims.mapClaims())
+	}
 
-	tokenString, err := token.SignedString(mySigningKey)
+	token := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims.mapClaims())
+	tokenString, err := token.SignedString([]byte(j.SignatureKey))
 
 	if err != nil {
 		return "", err
@@ -48,47 +88,115 @@ func (j *JWTImpl) GenerateToken(data map[string]interface{}) (string, error) {
 }
 
 func (j *JWTImpl) ValidateToken(tokenString string) (bool, error) {
-	token, err := gojwt.Parse(tokenString, func(token *gojwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*gojwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
-		return []byte(j.SignatureKey), nil
-	})
+	if _, err := j.ParseToken(tokenString); err != nil {
+		return false, err
+	}
+	return true, nil
+}
 
+func (j *JWTImpl) ParseToken(tokenString string) (*Claims, error) {
+	// The time based claims are checked below with the leeway
+	parser := &gojwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenString, j.keyFunc)
 	if err != nil {
-		return false, err
+		var validationErr *gojwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&gojwt.ValidationErrorSignatureInvalid != 0 {
+			return nil, ErrInvalidSignature
+		}
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
 	}
 
-	claims, ok := token.Claims.(gojwt.MapClaims)
+	raw, ok := token.Claims.(gojwt.MapClaims)
 	if !ok || !token.Valid {
-		return false, nil
+		return nil, ErrMalformedToken
 	}
 
-	expirationTime := claims["exp"].(float64)
-	if time.Now().Unix() > int64(expirationTime) {
-		return false, nil
+	claims, err := claimsFromMap(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.validate(claims, time.Now()); err != nil {
+		return nil, err
 	}
 
-	return true, nil
+	return claims, nil
 }
 
-func (j *JWTImpl) ParseToken(tokenString string) (map[string]interface{}, error) {
-	token, err := gojwt.Parse(tokenString, func(token *gojwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*gojwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+// validate checks the registered claims of a token whose signature has been
+// verified
+func (j *JWTImpl) validate(claims *Claims, now time.Time) error {
+	if claims.ExpiresAt.IsZero() {
+		return fmt.Errorf("%w: exp is required", ErrMalformedToken)
+	}
+	if now.After(claims.ExpiresAt.Add(j.Leeway)) {
+		return ErrTokenExpired
+	}
+	if !claims.NotBefore.IsZero() && now.Add(j.Leeway).Before(claims.NotBefore) {
+		return ErrTokenNotValidYet
+	}
+	if !claims.IssuedAt.IsZero() && now.Add(j.Leeway).Before(claims.IssuedAt) {
+		return ErrTokenNotValidYet
+	}
+
+	if j.Issuer != "" && claims.Issuer != j.Issuer {
+		return ErrInvalidIssuer
+	}
+
+	if len(j.Audience) > 0 {
+		accepted := false
+		for _, audience := range j.Audience {
+			if claims.HasAudience(audience) {
+				accepted = true
+				break
+			}
+		}
+		if !accepted {
+			return ErrInvalidAudience
 		}
-		return []byte(j.SignatureKey), nil
-	})
+	}
 
-	if err != nil {
-		return nil, err
+	return nil
+}
+
+// keyFunc returns the key verifying token
+func (j *JWTImpl) keyFunc(token *gojwt.Token) (interface{}, error) {
+	if j.Keys != nil {
+		return j.Keys.keyFunc(token)
+	}
+	if _, ok := token.Method.(*gojwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	}
+	return []byte(j.SignatureKey), nil
+}
 
-	claims, ok := token.Claims.(gojwt.MapClaims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+// JWKS returns the public keys verifying the tokens, which is empty when
+// tokens are signed with the shared SignatureKey
+func (j *JWTImpl) JWKS() JWKS {
+	if j.Keys == nil {
+		return JWKS{Keys: []JWK{}}
 	}
+	return j.Keys.JWKS()
+}
 
-	return claims, nil
+// newID returns a random token ID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// defaultJWT is the application's token issuer, set by the application
+// from the [jwt] section
+var defaultJWT JWT
+
+// SetDefault sets the JWT returned by Default
+func SetDefault(j JWT) {
+	defaultJWT = j
+}
+
+// Default returns the application's JWT
+func Default() JWT {
+	return defaultJWT
 }
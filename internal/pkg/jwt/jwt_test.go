@@ -0,0 +1,137 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt"
+)
+
+// sign signs raw claims with the test key, bypassing GenerateToken
+func sign(t *testing.T, claims gojwt.MapClaims) string {
+	t.Helper()
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestGenerateTokenFillsRegisteredClaims(t *testing.T) {
+	issuer := &JWTImpl{SignatureKey: "secret", TTL: 15 * time.Minute, Issuer: "auth", Audience: []string{"api"}}
+
+	token, err := issuer.GenerateToken(Claims{Subject: "42", Extra: map[string]interface{}{"email": "a@b.c", "exp": "ignored"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := issuer.ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "42" || claims.Issuer != "auth" || !claims.HasAudience("api") || claims.ID == "" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt); ttl != 15*time.Minute {
+		t.Errorf("expected a 15m lifetime, got %s", ttl)
+	}
+
+	// Times keep their milliseconds
+	issuedAt := time.Now().Truncate(time.Second).Add(-750*time.Millisecond - 300*time.Microsecond)
+	precise, _ := issuer.GenerateToken(Claims{Subject: "42", IssuedAt: issuedAt})
+	preciseClaims, err := issuer.ParseToken(precise)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := issuedAt.Truncate(time.Millisecond); !preciseClaims.IssuedAt.Equal(want) {
+		t.Errorf("expected iat %s, got %s", want.Format(time.RFC3339Nano), preciseClaims.IssuedAt.Format(time.RFC3339Nano))
+	}
+	if claims.String("email") != "a@b.c" {
+		t.Errorf("expected the custom email claim, got %v", claims.Extra)
+	}
+
+	other, _ := issuer.GenerateToken(Claims{Subject: "42"})
+	otherClaims, _ := issuer.ParseToken(other)
+	if otherClaims.ID == claims.ID {
+		t.Error("expected every token to get its own ID")
+	}
+}
+
+func TestParseTokenValidatesClaims(t *testing.T) {
+	now := time.Now()
+	issuer := &JWTImpl{SignatureKey: "secret", TTL: time.Minute, Issuer: "auth", Audience: []string{"api", "admin"}, Leeway: 30 * time.Second}
+
+	valid := func(overrides gojwt.MapClaims) gojwt.MapClaims {
+		claims := gojwt.MapClaims{"sub": "1", "iss": "auth", "aud": "api", "exp": now.Add(time.Minute).Unix(), "iat": now.Unix()}
+		for name, value := range overrides {
+			if value == nil {
+				delete(claims, name)
+				continue
+			}
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		claims gojwt.MapClaims
+		err    error
+	}{
+		{name: "valid", claims: valid(nil)},
+		{name: "audience list", claims: valid(gojwt.MapClaims{"aud": []string{"other", "admin"}})},
+		{name: "expired within leeway", claims: valid(gojwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()})},
+		{name: "expired", claims: valid(gojwt.MapClaims{"exp": now.Add(-time.Minute).Unix()}), err: ErrTokenExpired},
+		{name: "missing exp", claims: valid(gojwt.MapClaims{"exp": nil}), err: ErrMalformedToken},
+		{name: "exp not a number", claims: valid(gojwt.MapClaims{"exp": "tomorrow"}), err: ErrMalformedToken},
+		{name: "not before within leeway", claims: valid(gojwt.MapClaims{"nbf": now.Add(10 * time.Second).Unix()})},
+		{name: "not before", claims: valid(gojwt.MapClaims{"nbf": now.Add(time.Minute).Unix()}), err: ErrTokenNotValidYet},
+		{name: "issued in the future", claims: valid(gojwt.MapClaims{"iat": now.Add(time.Minute).Unix()}), err: ErrTokenNotValidYet},
+		{name: "wrong issuer", claims: valid(gojwt.MapClaims{"iss": "elsewhere"}), err: ErrInvalidIssuer},
+		{name: "missing issuer", claims: valid(gojwt.MapClaims{"iss": nil}), err: ErrInvalidIssuer},
+		{name: "wrong audience", claims: valid(gojwt.MapClaims{"aud": "billing"}), err: ErrInvalidAudience},
+		{name: "audience not a string", claims: valid(gojwt.MapClaims{"aud": 42}), err: ErrMalformedToken},
+		{name: "subject not a string", claims: valid(gojwt.MapClaims{"sub": 42}), err: ErrMalformedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := issuer.ParseToken(sign(t, tt.claims))
+			if tt.err == nil && err != nil {
+				t.Fatalf("expected the token to be valid, got %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			ok, validateErr := issuer.ValidateToken(sign(t, tt.claims))
+			if ok != (tt.err == nil) || (validateErr == nil) != (tt.err == nil) {
+				t.Errorf("ValidateToken returned %v, %v", ok, validateErr)
+			}
+		})
+	}
+}
+
+func TestParseTokenRejectsMalformedTokens(t *testing.T) {
+	issuer := &JWTImpl{SignatureKey: "secret", TTL: time.Minute}
+	unsigned, _ := gojwt.NewWithClaims(gojwt.SigningMethodNone, gojwt.MapClaims{"sub": "1"}).SignedString(gojwt.UnsafeAllowNoneSignatureType)
+	tampered := sign(t, gojwt.MapClaims{"sub": "1", "exp": time.Now().Add(time.Minute).Unix()})
+	tampered = tampered[:len(tampered)-2] + "xx"
+
+	for name, token := range map[string]string{
+		"empty":     "",
+		"garbage":   "not-a-token",
+		"two parts": "a.b",
+		"bad json":  "eyJhbGciOiJIUzI1NiJ9.bm90IGpzb24.c2ln",
+		"alg none":  unsigned,
+	} {
+		if _, err := issuer.ParseToken(token); !errors.Is(err, ErrMalformedToken) {
+			t.Errorf("%s: expected ErrMalformedToken, got %v", name, err)
+		}
+	}
+
+	if _, err := issuer.ParseToken(tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+}
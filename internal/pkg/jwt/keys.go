@@ -0,0 +1,197 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"os"
+
+	gojwt "github.com/golang-jwt/jwt"
+)
+
+// Supported asymmetric algorithms
+const (
+	RS256 = "RS256"
+	RS384 = "RS384"
+	RS512 = "RS512"
+	ES256 = "ES256"
+	ES384 = "ES384"
+	ES512 = "ES512"
+	EdDSA = "EdDSA"
+)
+
+// curves are the curves required by the ECDSA algorithms
+var curves = map[string]elliptic.Curve{
+	ES256: elliptic.P256(),
+	ES384: elliptic.P384(),
+	ES512: elliptic.P521(),
+}
+
+// KeyConfig describes a key stored in PEM files. A key with a private key
+// can sign tokens; a key with only a public key verifies tokens signed
+// before a rotation.
+type KeyConfig struct {
+	ID             string
+	Algorithm      string
+	PrivateKeyFile string
+	PublicKeyFile  string
+}
+
+// Key is a key identified by the kid header of the tokens it signs
+type Key struct {
+	ID        string
+	Algorithm string
+	method    gojwt.SigningMethod
+	private   crypto.PrivateKey
+	public    crypto.PublicKey
+}
+
+// CanSign reports whether the private key is available
+func (k *Key) CanSign() bool {
+	return k.private != nil
+}
+
+// LoadKey reads a key from its PEM files. The public key is derived from the
+// private key when PublicKeyFile is empty, and must match it otherwise.
+func LoadKey(cfg KeyConfig) (*Key, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("key has no id")
+	}
+
+	method := gojwt.GetSigningMethod(cfg.Algorithm)
+	switch method.(type) {
+	case *gojwt.SigningMethodRSA, *gojwt.SigningMethodECDSA, *gojwt.SigningMethodEd25519:
+	default:
+		return nil, fmt.Errorf("key %s: unsupported algorithm %q", cfg.ID, cfg.Algorithm)
+	}
+
+	key := &Key{ID: cfg.ID, Algorithm: cfg.Algorithm, method: method}
+
+	if cfg.PrivateKeyFile != "" {
+		content, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", cfg.ID, err)
+		}
+		if key.private, key.public, err = parsePrivateKey(method, content); err != nil {
+			return nil, fmt.Errorf("key %s: reading %s: %w", cfg.ID, cfg.PrivateKeyFile, err)
+		}
+	}
+
+	if cfg.PublicKeyFile != "" {
+		content, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", cfg.ID, err)
+		}
+		public, err := parsePublicKey(method, content)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: reading %s: %w", cfg.ID, cfg.PublicKeyFile, err)
+		}
+		// Tokens signed with the private key would fail verification, and
+		// the JWKS endpoint would publish the wrong key
+		if key.public != nil && !key.public.(interface{ Equal(crypto.PublicKey) bool }).Equal(public) {
+			return nil, fmt.Errorf("key %s: %s does not match the private key", cfg.ID, cfg.PublicKeyFile)
+		}
+		key.public = public
+	}
+
+	if key.public == nil {
+		return nil, fmt.Errorf("key %s: a private or public key file is required", cfg.ID)
+	}
+
+	if curve, ok := curves[cfg.Algorithm]; ok && key.public.(*ecdsa.PublicKey).Curve != curve {
+		return nil, fmt.Errorf("key %s: %s requires a %s key", cfg.ID, cfg.Algorithm, curve.Params().Name)
+	}
+
+	return key, nil
+}
+
+func parsePrivateKey(method gojwt.SigningMethod, content []byte) (crypto.PrivateKey, crypto.PublicKey, error) {
+	switch method.(type) {
+	case *gojwt.SigningMethodRSA:
+		key, err := gojwt.ParseRSAPrivateKeyFromPEM(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, &key.PublicKey, nil
+	case *gojwt.SigningMethodECDSA:
+		key, err := gojwt.ParseECPrivateKeyFromPEM(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, &key.PublicKey, nil
+	default:
+		key, err := gojwt.ParseEdPrivateKeyFromPEM(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, key.(ed25519.PrivateKey).Public(), nil
+	}
+}
+
+func parsePublicKey(method gojwt.SigningMethod, content []byte) (crypto.PublicKey, error) {
+	switch method.(type) {
+	case *gojwt.SigningMethodRSA:
+		return gojwt.ParseRSAPublicKeyFromPEM(content)
+	case *gojwt.SigningMethodECDSA:
+		return gojwt.ParseECPublicKeyFromPEM(content)
+	default:
+		return gojwt.ParseEdPublicKeyFromPEM(content)
+	}
+}
+
+// KeySet is the key used to sign new tokens along with the keys still
+// accepted when validating tokens
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	ordered []*Key
+}
+
+// NewKeySet creates a key set that signs with the key whose ID is signingID
+// and accepts tokens signed by any of keys
+func NewKeySet(signingID string, keys ...*Key) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*Key)}
+	for _, key := range keys {
+		if _, exists := set.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		set.keys[key.ID] = key
+		set.ordered = append(set.ordered, key)
+	}
+
+	signing, ok := set.keys[signingID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q is not in the key set", signingID)
+	}
+	if !signing.CanSign() {
+		return nil, fmt.Errorf("signing key %q has no private key", signingID)
+	}
+	set.signing = signing
+
+	return set, nil
+}
+
+// sign signs token with the signing key and sets its kid header
+func (s *KeySet) sign(claims gojwt.Claims) (string, error) {
+	token := gojwt.NewWithClaims(s.signing.method, claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.private)
+}
+
+// keyFunc returns the public key matching the token's kid header. The
+// token's algorithm must be the one of the key, so a public key can never
+// be used as an HMAC secret.
+func (s *KeySet) keyFunc(token *gojwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
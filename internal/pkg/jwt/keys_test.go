@@ -0,0 +1,186 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt"
+)
+
+// writeKey writes private to a PKCS#8 PEM file and its public key to a PKIX
+// PEM file, returning both paths
+func writeKey(t *testing.T, name string, private crypto.Signer) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateFile := filepath.Join(dir, name+".pem")
+	publicFile := filepath.Join(dir, name+".pub.pem")
+	if err := os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return privateFile, publicFile
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		algorithm string
+		key       crypto.Signer
+		kty       string
+	}{
+		{RS256, rsaKey, "RSA"},
+		{ES256, ecKey, "EC"},
+		{EdDSA, edKey, "OKP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			privateFile, _ := writeKey(t, "key", tt.key)
+			key, err := LoadKey(KeyConfig{ID: "k1", Algorithm: tt.algorithm, PrivateKeyFile: privateFile})
+			if err != nil {
+				t.Fatal(err)
+			}
+			set, err := NewKeySet("k1", key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			issuer := &JWTImpl{TTL: time.Minute, Keys: set}
+			token, err := issuer.GenerateToken(Claims{Subject: "42"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, _, err := new(gojwt.Parser).ParseUnverified(token, gojwt.MapClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["kid"] != "k1" || parsed.Header["alg"] != tt.algorithm {
+				t.Errorf("unexpected header %v", parsed.Header)
+			}
+
+			claims, err := issuer.ParseToken(token)
+			if err != nil || claims.Subject != "42" {
+				t.Errorf("expected the token to validate, got %v %v", claims, err)
+			}
+
+			jwks := issuer.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "k1" || jwks.Keys[0].KeyType != tt.kty {
+				t.Errorf("unexpected JWKS %+v", jwks)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	oldPrivate, oldPublic := writeKey(t, "old", oldKey)
+	newPrivate, _ := writeKey(t, "new", newKey)
+
+	old, _ := LoadKey(KeyConfig{ID: "old", Algorithm: ES256, PrivateKeyFile: oldPrivate})
+	oldSet, _ := NewKeySet("old", old)
+	before, _ := (&JWTImpl{TTL: time.Minute, Keys: oldSet}).GenerateToken(Claims{Subject: "1"})
+
+	// After the rotation the old key is only kept to verify tokens
+	current, err := LoadKey(KeyConfig{ID: "new", Algorithm: ES256, PrivateKeyFile: newPrivate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retired, err := LoadKey(KeyConfig{ID: "old", Algorithm: ES256, PublicKeyFile: oldPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKeySet("old", current, retired); err == nil {
+		t.Error("expected a key without private key to be refused for signing")
+	}
+
+	set, err := NewKeySet("new", current, retired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &JWTImpl{TTL: time.Minute, Keys: set}
+
+	if _, err := issuer.ParseToken(before); err != nil {
+		t.Errorf("expected a token signed with the retired key to validate, got %v", err)
+	}
+	after, _ := issuer.GenerateToken(Claims{Subject: "1"})
+	if _, err := issuer.ParseToken(after); err != nil {
+		t.Errorf("expected a token signed with the current key to validate, got %v", err)
+	}
+	if len(issuer.JWKS().Keys) != 2 {
+		t.Errorf("expected both keys in the JWKS, got %+v", issuer.JWKS())
+	}
+
+	// Only the new key remains
+	rotated, _ := NewKeySet("new", current)
+	if _, err := (&JWTImpl{Keys: rotated}).ParseToken(before); err == nil || !strings.Contains(err.Error(), "unknown key id") {
+		t.Errorf("expected the removed key to be rejected, got %v", err)
+	}
+}
+
+func TestRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	privateFile, publicFile := writeKey(t, "key", rsaKey)
+	key, _ := LoadKey(KeyConfig{ID: "k1", Algorithm: RS256, PrivateKeyFile: privateFile})
+	set, _ := NewKeySet("k1", key)
+	issuer := &JWTImpl{TTL: time.Minute, Keys: set}
+
+	// An HS256 token "signed" with the published public key
+	publicPEM, _ := os.ReadFile(publicFile)
+	forged := gojwt.NewWithClaims(gojwt.SigningMethodHS256, gojwt.MapClaims{"sub": "1", "exp": time.Now().Add(time.Minute).Unix()})
+	forged.Header["kid"] = "k1"
+	token, _ := forged.SignedString(publicPEM)
+
+	if _, err := issuer.ParseToken(token); err == nil {
+		t.Error("expected an HS256 token to be rejected by an RS256 key")
+	}
+}
+
+func TestLoadKeyRejectsWrongCurve(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	privateFile, _ := writeKey(t, "key", ecKey)
+	if _, err := LoadKey(KeyConfig{ID: "k1", Algorithm: ES256, PrivateKeyFile: privateFile}); err == nil {
+		t.Error("expected a P-384 key to be refused for ES256")
+	}
+}
+
+func TestLoadKeyRejectsMismatchedPublicKey(t *testing.T) {
+	first, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	second, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	privateFile, publicFile := writeKey(t, "first", first)
+	_, otherPublicFile := writeKey(t, "second", second)
+
+	if _, err := LoadKey(KeyConfig{ID: "k1", Algorithm: ES256, PrivateKeyFile: privateFile, PublicKeyFile: publicFile}); err != nil {
+		t.Fatalf("unexpected error for a matching pair: %v", err)
+	}
+	if _, err := LoadKey(KeyConfig{ID: "k1", Algorithm: ES256, PrivateKeyFile: privateFile, PublicKeyFile: otherPublicFile}); err == nil {
+		t.Error("expected a public key of another private key to be refused")
+	}
+}
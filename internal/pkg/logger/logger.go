@@ -23,7 +23,7 @@ type Logger struct {
 	zap    *zap.Logger
 	sugar  *zap.SugaredLogger
 	prefix string
-	level  zapcore.Level
+	level  zap.AtomicLevel
 }
 
 // Config holds the logger configuration
@@ -101,8 +101,9 @@ func NewLogger(config Config, prefix string) (*Logger, error) {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
-	// Determine the level
-	level := stringToZapLevel(config.Level)
+	// Determine the level, shared with every prefixed logger so it can be
+	// changed at runtime
+	level := zap.NewAtomicLevelAt(stringToZapLevel(config.Level))
 
 	// Create the core
 	var core zapcore.Core
@@ -177,6 +178,17 @@ func (l *Logger) Fatal(msg string, fields ...interface{}) {
 	l.sugar.Fatalw(msg, fields...)
 }
 
+// SetLevel changes the level of this logger and every logger derived from
+// it with WithPrefix
+func (l *Logger) SetLevel(level string) {
+	l.level.SetLevel(stringToZapLevel(level))
+}
+
+// Level returns the current log level
+func (l *Logger) Level() string {
+	return l.level.Level().String()
+}
+
 // Sync flushes the logger buffers
 func (l *Logger) Sync() error {
 	return l.zap.Sync()
@@ -0,0 +1,112 @@
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"io"
+	"mime"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// ContentType is the media type of JSON Merge Patch documents, RFC 7396
+const ContentType = "application/merge-patch+json"
+
+// ErrInvalidPatch is returned for a body that is not a JSON object, or
+// whose members do not fit the fields they patch
+var ErrInvalidPatch = errs.New(errs.Validation, "invalid_patch", "invalid merge patch")
+
+// Members are the top-level members of a patch, true for those set to null
+type Members map[string]bool
+
+// Has reports whether the patch sets or removes a member
+func (m Members) Has(name string) bool {
+	_, ok := m[name]
+	return ok
+}
+
+// NotNull returns a *NullError if the patch removes any of the members,
+// for fields that cannot be empty
+func (m Members) NotNull(names ...string) error {
+	var null []string
+	for _, name := range names {
+		if m[name] {
+			null = append(null, name)
+		}
+	}
+	if len(null) > 0 {
+		sort.Strings(null)
+		return &NullError{Members: null}
+	}
+	return nil
+}
+
+// Bind decodes a merge patch request body into dst and returns its members.
+// The fields of dst are expected to be pointers: members missing from the
+// patch, and members set to null, leave their field nil, so only the fields
+// that are present are validated with validate:"omitempty,...". Bodies sent
+// as application/json are accepted too.
+func Bind(c echo.Context, dst interface{}) (Members, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != ContentType && mediaType != echo.MIMEApplicationJSON {
+		return nil, echo.ErrUnsupportedMediaType
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		return nil, fmt.Errorf("%w: the patch must be a JSON object", ErrInvalidPatch)
+	}
+	if raw == nil {
+		// The body is null
+		return nil, fmt.Errorf("%w: the patch must be a JSON object", ErrInvalidPatch)
+	}
+	if err := json.Unmarshal(body, dst); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("%w: %s has the wrong type", ErrInvalidPatch, typeErr.Field)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	members := make(Members, len(raw))
+	for name, value := range raw {
+		members[name] = strings.TrimSpace(string(value)) == "null"
+	}
+	return members, nil
+}
+
+// NullError reports members set to null whose fields cannot be removed
+type NullError struct {
+	Members []string
+}
+
+func (e *NullError) Error() string {
+	return "cannot remove " + strings.Join(e.Members, ", ")
+}
+
+// ErrorKind implements errs.Kinded.
+func (e *NullError) ErrorKind() errs.Kind {
+	return errs.Validation
+}
+
+// FieldErrors implements errs.Fielded.
+func (e *NullError) FieldErrors() []errs.FieldError {
+	fields := make([]errs.FieldError, 0, len(e.Members))
+	for _, name := range e.Members {
+		fields = append(fields, errs.FieldError{Field: name, Code: "required", Message: name + " cannot be removed"})
+	}
+	return fields
+}
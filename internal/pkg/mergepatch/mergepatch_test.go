@@ -0,0 +1,62 @@
+package mergepatch
+
+import (
+	"errors"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+type profile struct {
+	Name *string `json:"name"`
+	Bio  *string `json:"bio"`
+	Age  *int    `json:"age"`
+}
+
+func bind(contentType, body string) (*profile, Members, error) {
+	req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	dst := new(profile)
+	members, err := Bind(c, dst)
+	return dst, members, err
+}
+
+func TestBind(t *testing.T) {
+	dst, members, err := bind(ContentType, `{"name": "bob", "bio": null}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.Name == nil || *dst.Name != "bob" || dst.Bio != nil || dst.Age != nil {
+		t.Errorf("unexpected fields %+v", dst)
+	}
+	if !members.Has("name") || !members.Has("bio") || members.Has("age") {
+		t.Errorf("unexpected members %v", members)
+	}
+
+	err = members.NotNull("name", "bio", "age")
+	var null *NullError
+	if !errors.As(err, &null) || len(null.Members) != 1 || null.Members[0] != "bio" {
+		t.Errorf("expected bio to be reported as null, got %v", err)
+	}
+	if fields := errs.FieldsOf(err); len(fields) != 1 || fields[0].Field != "bio" {
+		t.Errorf("unexpected field errors %+v", fields)
+	}
+
+	if _, _, err := bind(echo.MIMEApplicationJSONCharsetUTF8, `{}`); err != nil {
+		t.Errorf("expected application/json to be accepted, got %v", err)
+	}
+	if _, _, err := bind(echo.MIMETextPlain, `{}`); !errors.Is(err, echo.ErrUnsupportedMediaType) {
+		t.Errorf("expected an unsupported media type, got %v", err)
+	}
+	for _, body := range []string{`null`, `[]`, `"bob"`, `{"age": "ten"}`, `{`} {
+		if _, _, err := bind(ContentType, body); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%s: expected ErrInvalidPatch, got %v", body, err)
+		}
+	}
+}
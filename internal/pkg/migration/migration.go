@@ -0,0 +1,158 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a single versioned schema change. A migration is either a
+// pair of Go functions or a pair of SQL scripts; the functions win when both
+// are set.
+type Migration struct {
+	// Version orders the migrations of a module; it must be unique per module
+	Version int64
+
+	// Name is a short description, e.g. "create_users"
+	Name string
+
+	Up   func(tx *gorm.DB) error
+	Down func(tx *gorm.DB) error
+
+	UpSQL   string
+	DownSQL string
+}
+
+// checksum identifies the content of the migration so edits to an already
+// applied migration can be detected. Go migrations can only be identified
+// by their version and name.
+func (m Migration) checksum() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%s", m.Version, m.Name)
+	if m.Up == nil {
+		h.Write([]byte(m.UpSQL))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (m Migration) up(tx *gorm.DB) error {
+	if m.Up != nil {
+		return m.Up(tx)
+	}
+	if m.UpSQL == "" {
+		return fmt.Errorf("migration %d_%s has no up step", m.Version, m.Name)
+	}
+	return tx.Exec(m.UpSQL).Error
+}
+
+func (m Migration) down(tx *gorm.DB) error {
+	if m.Down != nil {
+		return m.Down(tx)
+	}
+	if m.DownSQL == "" {
+		return fmt.Errorf("migration %d_%s is irreversible", m.Version, m.Name)
+	}
+	return tx.Exec(m.DownSQL).Error
+}
+
+// Set is the ordered list of migrations shipped by a module
+type Set struct {
+	Module     string
+	Migrations []Migration
+}
+
+// Record is a row of the schema table, one per applied migration
+type Record struct {
+	Module    string `gorm:"primaryKey;size:100"`
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	Checksum  string `gorm:"size:64"`
+	AppliedAt time.Time
+}
+
+// TableName specifies the table name for Record
+func (*Record) TableName() string {
+	return "schema_migrations"
+}
+
+// lock is the single row that guards against concurrent runs
+type lock struct {
+	ID       int    `gorm:"primaryKey;autoIncrement:false"`
+	LockedBy string `gorm:"size:255"`
+	LockedAt time.Time
+}
+
+// TableName specifies the table name for lock
+func (*lock) TableName() string {
+	return "schema_migrations_lock"
+}
+
+var sqlFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// FromFS loads SQL migrations from dir. Files must be named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, e.g.
+// 0001_create_users.up.sql. The down file is optional.
+func FromFS(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := sqlFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid version: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.UpSQL = string(content)
+		} else {
+			m.DownSQL = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpSQL == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,208 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	model := &database.DBModel{Driver: "sqlite", Name: database.SQLITE_MEMORY}
+	db, err := model.OpenDB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db
+}
+
+func testLogger(t *testing.T) *logger.Logger {
+	t.Helper()
+
+	cfg := logger.DefaultConfig()
+	cfg.OutputPath = filepath.Join(t.TempDir(), "test.log")
+	log, err := logger.NewLogger(cfg, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log
+}
+
+var sqlFiles = fstest.MapFS{
+	"migrations/0001_create_things.up.sql":   {Data: []byte("CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT)")},
+	"migrations/0001_create_things.down.sql": {Data: []byte("DROP TABLE things")},
+	"migrations/0002_add_color.up.sql":       {Data: []byte("ALTER TABLE things ADD COLUMN color TEXT")},
+	"migrations/0002_add_color.down.sql":     {Data: []byte("ALTER TABLE things DROP COLUMN color")},
+	"migrations/README.md":                   {Data: []byte("ignored")},
+}
+
+func TestFromFS(t *testing.T) {
+	migrations, err := FromFS(sqlFiles, "migrations")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "create_things" || migrations[0].DownSQL == "" {
+		t.Errorf("unexpected first migration %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].Name != "add_color" {
+		t.Errorf("unexpected second migration %+v", migrations[1])
+	}
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	sqlMigrations, err := FromFS(sqlFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type widget struct {
+		ID   uint
+		Name string
+	}
+	goMigrations := []Migration{{
+		Version: 1,
+		Name:    "create_widgets",
+		Up:      func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&widget{}) },
+		Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&widget{}) },
+	}}
+
+	m := NewMigrator(db, testLogger(t),
+		Set{Module: "things", Migrations: sqlMigrations},
+		Set{Module: "widgets", Migrations: goMigrations},
+	)
+
+	if pending, err := m.Pending(ctx); err != nil || pending != 3 {
+		t.Fatalf("expected 3 pending migrations, got %d (%v)", pending, err)
+	}
+	if db.Migrator().HasTable(&Record{}) || db.Migrator().HasTable(&lock{}) {
+		t.Fatal("expected pending to leave the database untouched")
+	}
+
+	if count, err := m.Up(ctx); err != nil || count != 3 {
+		t.Fatalf("expected 3 applied migrations, got %d (%v)", count, err)
+	}
+	if !db.Migrator().HasColumn("things", "color") || !db.Migrator().HasTable(&widget{}) {
+		t.Fatal("migrations were not applied")
+	}
+
+	// Nothing left to do
+	if count, err := m.Up(ctx); err != nil || count != 0 {
+		t.Fatalf("expected no migrations to apply, got %d (%v)", count, err)
+	}
+
+	// Rolls back in reverse order: widgets first, then things 0002
+	if count, err := m.Down(ctx, 2); err != nil || count != 2 {
+		t.Fatalf("expected 2 rolled back migrations, got %d (%v)", count, err)
+	}
+	if db.Migrator().HasTable(&widget{}) || db.Migrator().HasColumn("things", "color") {
+		t.Fatal("migrations were not rolled back")
+	}
+
+	if err := m.Redo(ctx); err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := 0
+	for _, s := range statuses {
+		if s.Applied {
+			applied++
+		}
+	}
+	if applied != 1 {
+		t.Errorf("expected 1 applied migration after redo, got %d", applied)
+	}
+}
+
+func TestMigratorDownFollowsAppliedOrder(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	sqlMigrations, err := FromFS(sqlFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type widget struct {
+		ID uint
+	}
+	widgets := Set{Module: "widgets", Migrations: []Migration{{
+		Version: 1,
+		Name:    "create_widgets",
+		Up:      func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&widget{}) },
+		Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&widget{}) },
+	}}}
+
+	// The things set is registered after widgets were migrated, but comes
+	// first in the plan
+	if _, err := NewMigrator(db, testLogger(t), widgets).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMigrator(db, testLogger(t), Set{Module: "things", Migrations: sqlMigrations}, widgets)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := m.Down(ctx, 1); err != nil || count != 1 {
+		t.Fatalf("expected 1 rolled back migration, got %d (%v)", count, err)
+	}
+	if db.Migrator().HasColumn("things", "color") || !db.Migrator().HasTable(&widget{}) {
+		t.Error("expected the last applied migration, things 0002, to be rolled back")
+	}
+}
+
+func TestMigratorDetectsModifiedMigration(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	original := []Migration{{Version: 1, Name: "create_things", UpSQL: "CREATE TABLE things (id INTEGER)"}}
+	if _, err := NewMigrator(db, testLogger(t), Set{Module: "things", Migrations: original}).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := []Migration{
+		{Version: 1, Name: "create_things", UpSQL: "CREATE TABLE things (id INTEGER, name TEXT)"},
+		{Version: 2, Name: "noop", UpSQL: "SELECT 1"},
+	}
+	_, err := NewMigrator(db, testLogger(t), Set{Module: "things", Migrations: edited}).Up(ctx)
+	if err == nil {
+		t.Fatal("expected an error for a modified migration")
+	}
+}
+
+func TestMigratorLock(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	m := NewMigrator(db, testLogger(t))
+
+	if err := m.prepare(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&lock{ID: 1, LockedBy: "other"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(ctx); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"os"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrLocked is returned when another process is running migrations
+var ErrLocked = errors.New("migrations are locked by another process")
+
+// Status describes the state of a single migration
+type Status struct {
+	Module    string
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+
+	// Modified is set when the applied checksum differs from the migration
+	Modified bool
+
+	// Missing is set when the migration is recorded as applied but no
+	// longer shipped by its module
+	Missing bool
+}
+
+// Migrator applies and rolls back the migrations of a list of modules. The
+// order of the sets is the order modules are migrated in.
+type Migrator struct {
+	db   *gorm.DB
+	log  *logger.Logger
+	sets []Set
+}
+
+// step is a migration bound to the module that ships it
+type step struct {
+	module string
+	Migration
+}
+
+// NewMigrator creates a new migrator
+func NewMigrator(db *gorm.DB, log *logger.Logger, sets ...Set) *Migrator {
+	return &Migrator{db: db, log: log, sets: sets}
+}
+
+// plan returns every migration in the order they are applied
+func (m *Migrator) plan() ([]step, error) {
+	var steps []step
+	for _, set := range m.sets {
+		seen := make(map[int64]bool, len(set.Migrations))
+		var last int64
+		for i, migration := range set.Migrations {
+			if seen[migration.Version] {
+				return nil, fmt.Errorf("module %s: duplicate migration version %d", set.Module, migration.Version)
+			}
+			if i > 0 && migration.Version < last {
+				return nil, fmt.Errorf("module %s: migration %d is declared after %d", set.Module, migration.Version, last)
+			}
+			seen[migration.Version] = true
+			last = migration.Version
+			steps = append(steps, step{module: set.Module, Migration: migration})
+		}
+	}
+	return steps, nil
+}
+
+// applied returns the schema table rows keyed by module and version
+func (m *Migrator) applied(ctx context.Context) (map[string]map[int64]Record, error) {
+	var records []Record
+	if err := m.db.WithContext(ctx).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]map[int64]Record)
+	for _, record := range records {
+		if applied[record.Module] == nil {
+			applied[record.Module] = make(map[int64]Record)
+		}
+		applied[record.Module][record.Version] = record
+	}
+	return applied, nil
+}
+
+// prepare creates the bookkeeping tables
+func (m *Migrator) prepare(ctx context.Context) error {
+	return m.db.WithContext(ctx).AutoMigrate(&Record{}, &lock{})
+}
+
+// withLock runs fn while holding the migration lock
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if err := m.prepare(ctx); err != nil {
+		return err
+	}
+
+	host, _ := os.Hostname()
+	held := lock{ID: 1, LockedBy: fmt.Sprintf("%s:%d", host, os.Getpid()), LockedAt: time.Now()}
+
+	// The primary key makes the insert fail while another process holds the row
+	if err := m.db.WithContext(ctx).Create(&held).Error; err != nil {
+		var current lock
+		if m.db.WithContext(ctx).First(&current, 1).Error == nil {
+			return fmt.Errorf("%w: held by %s since %s (delete the row from %s if that process is gone)",
+				ErrLocked, current.LockedBy, current.LockedAt.Format(time.RFC3339), current.TableName())
+		}
+		return err
+	}
+
+	defer func() {
+		if err := m.db.Delete(&lock{}, 1).Error; err != nil {
+			m.log.Error("Failed to release migration lock", "error", err.Error())
+		}
+	}()
+
+	return fn()
+}
+
+// appliedSteps returns the applied migrations of steps, the most recently
+// applied first, which is the order they are rolled back in. Migrations
+// applied in the same run keep the reverse of the plan order.
+func appliedSteps(steps []step, applied map[string]map[int64]Record) []step {
+	var done []step
+	for i := len(steps) - 1; i >= 0; i-- {
+		if _, ok := applied[steps[i].module][steps[i].Version]; ok {
+			done = append(done, steps[i])
+		}
+	}
+	sort.SliceStable(done, func(i, j int) bool {
+		return applied[done[i].module][done[i].Version].AppliedAt.After(applied[done[j].module][done[j].Version].AppliedAt)
+	})
+	return done
+}
+
+// verify makes sure applied migrations have not been edited since
+func (m *Migrator) verify(steps []step, applied map[string]map[int64]Record) error {
+	for _, s := range steps {
+		record, ok := applied[s.module][s.Version]
+		if ok && record.Checksum != s.checksum() {
+			return fmt.Errorf("module %s: migration %d_%s was modified after it was applied", s.module, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies every pending migration and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	steps, err := m.plan()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(steps, applied); err != nil {
+			return err
+		}
+
+		for _, s := range steps {
+			if _, ok := applied[s.module][s.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, s); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// Down rolls back the last n applied migrations, most recently applied
+// first, and returns how many were rolled back
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	steps, err := m.plan()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(steps, applied); err != nil {
+			return err
+		}
+
+		for _, s := range appliedSteps(steps, applied) {
+			if count == n {
+				break
+			}
+			if err := m.rollback(ctx, s); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// Redo rolls back the last applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) error {
+	steps, err := m.plan()
+	if err != nil {
+		return err
+	}
+
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(steps, applied); err != nil {
+			return err
+		}
+
+		done := appliedSteps(steps, applied)
+		if len(done) == 0 {
+			return errors.New("no applied migration to redo")
+		}
+		if err := m.rollback(ctx, done[0]); err != nil {
+			return err
+		}
+		return m.apply(ctx, done[0])
+	})
+}
+
+// Status reports the state of every known or applied migration. It only
+// reads the schema table; before the first Up every migration is pending.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	steps, err := m.plan()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]map[int64]Record)
+	if m.db.WithContext(ctx).Migrator().HasTable(&Record{}) {
+		if applied, err = m.applied(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(steps))
+	for _, s := range steps {
+		status := Status{Module: s.module, Version: s.Version, Name: s.Name}
+		if record, ok := applied[s.module][s.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+			status.Modified = record.Checksum != s.checksum()
+			delete(applied[s.module], s.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	// Whatever is left is recorded but no longer shipped
+	for _, records := range applied {
+		for _, record := range records {
+			statuses = append(statuses, Status{
+				Module:    record.Module,
+				Version:   record.Version,
+				Name:      record.Name,
+				Applied:   true,
+				AppliedAt: record.AppliedAt,
+				Missing:   true,
+			})
+		}
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the number of migrations that have not been applied yet
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, s step) error {
+	m.log.Info("Applying migration", "module", s.module, "version", s.Version, "name", s.Name)
+
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&Record{
+			Module:    s.module,
+			Version:   s.Version,
+			Name:      s.Name,
+			Checksum:  s.checksum(),
+			AppliedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("applying %s/%d_%s: %w", s.module, s.Version, s.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) rollback(ctx context.Context, s step) error {
+	m.log.Info("Rolling back migration", "module", s.module, "version", s.Version, "name", s.Name)
+
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.down(tx); err != nil {
+			return err
+		}
+		return tx.Where("module = ? AND version = ?", s.module, s.Version).Delete(&Record{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("rolling back %s/%d_%s: %w", s.module, s.Version, s.Name, err)
+	}
+	return nil
+}
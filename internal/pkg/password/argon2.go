@@ -0,0 +1,126 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2idConfig holds the argon2id parameters
+type Argon2idConfig struct {
+	Memory      uint32 `mapstructure:"memory" validate:"min=8"` // KiB
+	Iterations  uint32 `mapstructure:"iterations" validate:"min=1"`
+	Parallelism uint8  `mapstructure:"parallelism" validate:"min=1"`
+	SaltLength  uint32 `mapstructure:"salt_length" validate:"min=8"`
+	KeyLength   uint32 `mapstructure:"key_length" validate:"min=16"`
+}
+
+// DefaultArgon2idConfig returns 64 MiB of memory, 3 iterations and 2 lanes
+func DefaultArgon2idConfig() Argon2idConfig {
+	return Argon2idConfig{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+type argon2idHasher struct {
+	cfg Argon2idConfig
+}
+
+func newArgon2id(cfg Argon2idConfig) *argon2idHasher {
+	return &argon2idHasher{cfg: cfg}
+}
+
+// Hash returns a hash in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (a *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, a.cfg.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.cfg.Iterations, a.cfg.Memory, a.cfg.Parallelism, a.cfg.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.cfg.Memory, a.cfg.Iterations, a.cfg.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *argon2idHasher) Verify(hash, password string) error {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (a *argon2idHasher) NeedsRehash(hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != a.cfg.Memory ||
+		params.Iterations != a.cfg.Iterations ||
+		params.Parallelism != a.cfg.Parallelism ||
+		uint32(len(salt)) != a.cfg.SaltLength ||
+		uint32(len(key)) != a.cfg.KeyLength
+}
+
+func (a *argon2idHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+// decodeArgon2id reads the parameters, salt and key of a PHC string. A
+// corrupted hash with an empty key would match any password, and zero
+// iterations or lanes make argon2 panic, so they are ErrUnknownFormat.
+func decodeArgon2id(hash string) (Argon2idConfig, []byte, []byte, error) {
+	var params Argon2idConfig
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("reading argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("reading argon2id parameters: %w", err)
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, ErrUnknownFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("reading argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("reading argon2id key: %w", err)
+	}
+	if len(salt) == 0 || len(key) == 0 {
+		return params, nil, nil, ErrUnknownFormat
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,64 @@
+package password
+
+import (
+	"errors"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptConfig holds the bcrypt parameters
+type BcryptConfig struct {
+	Cost int `mapstructure:"cost" validate:"min=10,max=31"`
+}
+
+// DefaultBcryptConfig returns a cost of 12
+func DefaultBcryptConfig() BcryptConfig {
+	return BcryptConfig{Cost: 12}
+}
+
+// BcryptMaxLength is the number of bytes of a password bcrypt can hash
+const BcryptMaxLength = 72
+
+// ErrTooLong is returned when bcrypt is selected and a password is longer
+// than BcryptMaxLength
+var ErrTooLong = errs.New(errs.Validation, "password_too_long", "password must be at most 72 bytes")
+
+type bcryptHasher struct {
+	cost int
+}
+
+func newBcrypt(cfg BcryptConfig) *bcryptHasher {
+	return &bcryptHasher{cost: cfg.Cost}
+}
+
+// Hash returns a $2a$<cost>$... hash. Passwords longer than
+// BcryptMaxLength are rejected with ErrTooLong rather than truncated.
+func (b *bcryptHasher) Hash(password string) (string, error) {
+	if len(password) > BcryptMaxLength {
+		return "", ErrTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (b *bcryptHasher) Verify(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+	return err
+}
+
+func (b *bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.cost
+}
+
+func (b *bcryptHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
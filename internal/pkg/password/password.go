@@ -0,0 +1,122 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Supported algorithms
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var (
+	// ErrMismatch is returned when a password does not match its hash
+	ErrMismatch = errors.New("password does not match")
+
+	// ErrUnknownFormat is returned for hashes no algorithm recognizes
+	ErrUnknownFormat = errors.New("unknown password hash format")
+)
+
+// Hasher hashes passwords and verifies them against stored hashes
+type Hasher interface {
+	// Hash returns a self-describing hash of password
+	Hash(password string) (string, error)
+
+	// Verify returns nil if password matches hash, ErrMismatch if it does
+	// not and another error if hash cannot be read
+	Verify(hash, password string) error
+
+	// NeedsRehash reports whether hash was made with another algorithm or
+	// other parameters than the ones currently configured
+	NeedsRehash(hash string) bool
+}
+
+// Config selects the algorithm used for new hashes and its parameters
+type Config struct {
+	Algorithm string         `mapstructure:"algorithm" validate:"oneof=bcrypt argon2id"`
+	Bcrypt    BcryptConfig   `mapstructure:"bcrypt"`
+	Argon2id  Argon2idConfig `mapstructure:"argon2id"`
+}
+
+// DefaultConfig returns argon2id with the parameters recommended by OWASP
+func DefaultConfig() Config {
+	return Config{
+		Algorithm: Argon2id,
+		Bcrypt:    DefaultBcryptConfig(),
+		Argon2id:  DefaultArgon2idConfig(),
+	}
+}
+
+// New returns a Hasher that hashes with the configured algorithm and
+// verifies hashes made with any supported algorithm
+func New(cfg Config) (Hasher, error) {
+	hashers := map[string]algorithm{
+		Bcrypt:   newBcrypt(cfg.Bcrypt),
+		Argon2id: newArgon2id(cfg.Argon2id),
+	}
+
+	current, ok := hashers[cfg.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported password algorithm %q", cfg.Algorithm)
+	}
+
+	return &hasher{current: current, all: hashers}, nil
+}
+
+// algorithm is a Hasher for a single hash format
+type algorithm interface {
+	Hasher
+
+	// Recognizes reports whether hash is in this algorithm's format
+	Recognizes(hash string) bool
+}
+
+type hasher struct {
+	current algorithm
+	all     map[string]algorithm
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+func (h *hasher) Verify(hash, password string) error {
+	algorithm := h.find(hash)
+	if algorithm == nil {
+		return ErrUnknownFormat
+	}
+	return algorithm.Verify(hash, password)
+}
+
+func (h *hasher) NeedsRehash(hash string) bool {
+	if !h.current.Recognizes(hash) {
+		return true
+	}
+	return h.current.NeedsRehash(hash)
+}
+
+func (h *hasher) find(hash string) algorithm {
+	if !strings.HasPrefix(hash, "$") {
+		return nil
+	}
+	for _, algorithm := range h.all {
+		if algorithm.Recognizes(hash) {
+			return algorithm
+		}
+	}
+	return nil
+}
+
+// IsHash reports whether s is in the format of a supported algorithm, as
+// opposed to a password stored in plain text
+func IsHash(s string) bool {
+	for _, algorithm := range []algorithm{&bcryptHasher{}, &argon2idHasher{}} {
+		if algorithm.Recognizes(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package password
+
+import (
+	"errors"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"strings"
+	"testing"
+)
+
+// fast keeps the tests quick; production parameters are far higher
+func fast(algorithm string) Config {
+	return Config{
+		Algorithm: algorithm,
+		Bcrypt:    BcryptConfig{Cost: 10},
+		Argon2id:  Argon2idConfig{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	for _, algorithm := range []string{Bcrypt, Argon2id} {
+		t.Run(algorithm, func(t *testing.T) {
+			hasher, err := New(fast(algorithm))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hash, err := hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(hash, "correct horse") {
+				t.Fatalf("hash contains the password: %s", hash)
+			}
+
+			if err := hasher.Verify(hash, "correct horse"); err != nil {
+				t.Errorf("expected the password to match, got %v", err)
+			}
+			if err := hasher.Verify(hash, "battery staple"); !errors.Is(err, ErrMismatch) {
+				t.Errorf("expected ErrMismatch, got %v", err)
+			}
+			if hasher.NeedsRehash(hash) {
+				t.Error("a fresh hash should not need rehashing")
+			}
+		})
+	}
+}
+
+func TestBcryptRejectsLongPasswords(t *testing.T) {
+	hasher, _ := New(fast(Bcrypt))
+
+	if _, err := hasher.Hash(strings.Repeat("a", BcryptMaxLength)); err != nil {
+		t.Errorf("expected a %d byte password to hash, got %v", BcryptMaxLength, err)
+	}
+
+	_, err := hasher.Hash(strings.Repeat("a", BcryptMaxLength+1))
+	if !errors.Is(err, ErrTooLong) || !errors.Is(err, errs.Validation) {
+		t.Errorf("expected ErrTooLong as a validation error, got %v", err)
+	}
+
+	// argon2id has no such limit
+	argon2id, _ := New(fast(Argon2id))
+	if _, err := argon2id.Hash(strings.Repeat("a", BcryptMaxLength+1)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestArgon2idFormat(t *testing.T) {
+	hasher, _ := New(fast(Argon2id))
+	hash, _ := hasher.Hash("secret")
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("unexpected hash format %s", hash)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	old, _ := New(fast(Bcrypt))
+	hash, _ := old.Hash("secret")
+
+	// Hashes made with the previous algorithm still verify
+	current, _ := New(fast(Argon2id))
+	if err := current.Verify(hash, "secret"); err != nil {
+		t.Errorf("expected the bcrypt hash to verify, got %v", err)
+	}
+	if !current.NeedsRehash(hash) {
+		t.Error("expected a bcrypt hash to need rehashing to argon2id")
+	}
+
+	stronger := fast(Argon2id)
+	stronger.Argon2id.Iterations = 2
+	upgraded, _ := New(stronger)
+	argonHash, _ := current.Hash("secret")
+	if !upgraded.NeedsRehash(argonHash) {
+		t.Error("expected a hash with fewer iterations to need rehashing")
+	}
+
+	costlier := fast(Bcrypt)
+	costlier.Bcrypt.Cost = 11
+	bcryptUpgraded, _ := New(costlier)
+	if !bcryptUpgraded.NeedsRehash(hash) {
+		t.Error("expected a hash with a lower cost to need rehashing")
+	}
+}
+
+func TestVerifyUnknownFormat(t *testing.T) {
+	hasher, _ := New(fast(Argon2id))
+	if err := hasher.Verify("plaintext", "plaintext"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+	if !hasher.NeedsRehash("plaintext") {
+		t.Error("expected an unknown format to need rehashing")
+	}
+}
+
+func TestArgon2idRejectsCorruptedHashes(t *testing.T) {
+	hasher, _ := New(fast(Argon2id))
+	for _, hash := range []string{
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0$",
+		"$argon2id$v=19$m=64,t=1,p=1$$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHRzYWx0$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=1,p=0$c2FsdHNhbHRzYWx0$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+	} {
+		if err := hasher.Verify(hash, "anything"); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("expected ErrUnknownFormat for %s, got %v", hash, err)
+		}
+	}
+}
+
+func TestNewRejectsUnknownAlgorithm(t *testing.T) {
+	if _, err := New(Config{Algorithm: "md5"}); err == nil {
+		t.Error("expected an error for an unsupported algorithm")
+	}
+}
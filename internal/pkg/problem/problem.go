@@ -0,0 +1,110 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object, extended with a stable
+// error code, the request ID and the fields that failed validation
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      string            `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    []errs.FieldError `json:"errors,omitempty"`
+}
+
+// Config configures the error handler
+type Config struct {
+	// Production hides the details of internal errors from clients
+	Production bool
+	// Logger logs internal errors, they are not logged when it is nil
+	Logger *logger.Logger
+}
+
+// New describes err as a problem. Domain errors get the status and code of
+// their kind, echo's HTTP errors keep their status, and any other error is
+// an internal error whose detail is hidden in production.
+func New(err error, production bool) *Problem {
+	p := &Problem{Type: "about:blank"}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		p.Status = httpErr.Code
+		p.Code = statusCode(httpErr.Code)
+		p.Detail = fmt.Sprint(httpErr.Message)
+	} else {
+		p.Status = errs.HTTPStatus(err)
+		p.Code = errs.CodeOf(err)
+		p.Detail = err.Error()
+		p.Errors = errs.FieldsOf(err)
+	}
+
+	p.Title = http.StatusText(p.Status)
+	if p.Status >= http.StatusInternalServerError && production {
+		p.Detail = ""
+	}
+	return p
+}
+
+// Handler returns an echo.HTTPErrorHandler rendering the errors returned by
+// handlers and middleware as application/problem+json, localized in the
+// language of the Accept-Language header when they can be
+func Handler(cfg Config) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		p := New(errs.Localize(err, c.Request().Header.Get("Accept-Language")), cfg.Production)
+		p.Instance = c.Request().URL.Path
+		p.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+
+		if p.Status >= http.StatusInternalServerError && cfg.Logger != nil {
+			cfg.Logger.Error("Request failed",
+				"method", c.Request().Method,
+				"instance", p.Instance,
+				"request_id", p.RequestID,
+				"error", err.Error(),
+			)
+		}
+
+		if c.Response().Committed {
+			return
+		}
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(p.Status)
+		} else {
+			var body []byte
+			if body, err = json.Marshal(p); err == nil {
+				err = c.Blob(p.Status, ContentType, body)
+			}
+		}
+		if err != nil && cfg.Logger != nil {
+			cfg.Logger.Error("Failed to write error response",
+				"request_id", p.RequestID,
+				"error", err.Error(),
+			)
+		}
+	}
+}
+
+// statusCode derives an error code from an HTTP status, e.g.
+// method_not_allowed
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "internal"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
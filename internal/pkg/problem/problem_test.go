@@ -0,0 +1,80 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+)
+
+type fieldsError struct{}
+
+func (fieldsError) Error() string        { return "invalid request" }
+func (fieldsError) ErrorKind() errs.Kind { return errs.Validation }
+func (fieldsError) FieldErrors() []errs.FieldError {
+	return []errs.FieldError{{Field: "email", Code: "required", Message: "email is required"}}
+}
+
+func TestHandler(t *testing.T) {
+	for _, production := range []bool{false, true} {
+		e := echo.New()
+		e.HTTPErrorHandler = Handler(Config{Production: production})
+		e.Use(middleware.RequestID())
+		e.GET("/widgets/:id", func(c echo.Context) error {
+			return fmt.Errorf("get widget: %w", errs.New(errs.NotFound, "widget_not_found", "widget not found"))
+		})
+		e.POST("/widgets", func(c echo.Context) error {
+			return fieldsError{}
+		})
+		e.PUT("/widgets/:id", func(c echo.Context) error {
+			return errors.New("sql: database is closed")
+		})
+
+		tests := []struct {
+			method string
+			path   string
+			want   Problem
+		}{
+			{http.MethodGet, "/widgets/9", Problem{Status: 404, Title: "Not Found", Code: "widget_not_found", Detail: "get widget: widget not found"}},
+			{http.MethodPost, "/widgets", Problem{Status: 400, Title: "Bad Request", Code: "validation", Detail: "invalid request"}},
+			{http.MethodDelete, "/widgets", Problem{Status: 405, Title: "Method Not Allowed", Code: "method_not_allowed", Detail: "Method Not Allowed"}},
+			{http.MethodPut, "/widgets/9", Problem{Status: 500, Title: "Internal Server Error", Code: "internal", Detail: "sql: database is closed"}},
+		}
+
+		for _, tt := range tests {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.want.Status {
+				t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.want.Status, rec.Code)
+			}
+			if rec.Header().Get(echo.HeaderContentType) != ContentType {
+				t.Errorf("%s %s: unexpected content type %q", tt.method, tt.path, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			var got Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want.Detail
+			if production && tt.want.Status == http.StatusInternalServerError {
+				want = ""
+			}
+			if got.Title != tt.want.Title || got.Code != tt.want.Code || got.Detail != want {
+				t.Errorf("%s %s: unexpected problem %+v", tt.method, tt.path, got)
+			}
+			if got.Instance != tt.path || got.RequestID == "" || got.RequestID != rec.Header().Get(echo.HeaderXRequestID) {
+				t.Errorf("%s %s: expected the path and the request ID, got %+v", tt.method, tt.path, got)
+			}
+			if tt.method == http.MethodPost && (len(got.Errors) != 1 || got.Errors[0].Field != "email") {
+				t.Errorf("expected the field errors, got %+v", got.Errors)
+			}
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package query
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Page is a page of results with the metadata to fetch the next one
+type Page[T any] struct {
+	Items []T `json:"items"`
+	// Total counts the rows matching the filters on every page
+	Total  int64 `json:"total"`
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset,omitempty"`
+	// NextCursor fetches the next page, it is empty on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Map converts the items of a page, e.g. entities to responses
+func Map[T, R any](page *Page[T], fn func(T) R) *Page[R] {
+	items := make([]R, len(page.Items))
+	for i, item := range page.Items {
+		items[i] = fn(item)
+	}
+	return &Page[R]{
+		Items:      items,
+		Total:      page.Total,
+		Limit:      page.Limit,
+		Offset:     page.Offset,
+		NextCursor: page.NextCursor,
+	}
+}
+
+// cursor is the position after the last row of a page: the values of its
+// sort fields, with the sort they belong to
+type cursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+var schemas sync.Map
+
+// Find loads the page of T described by spec. db is the query to page
+// through, e.g. database.Conn(ctx) with additional conditions; the spec's
+// filters, sort and pagination are added to it.
+func Find[T any](db *gorm.DB, s *Schema, spec *Spec) (*Page[T], error) {
+	if err := s.Validate(spec); err != nil {
+		return nil, err
+	}
+
+	filtered := db
+	for _, filter := range spec.Filters {
+		var err error
+		if filtered, err = s.where(filtered, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	var items []T
+	page := &Page[T]{Limit: spec.Limit, Offset: spec.Offset}
+	if err := filtered.Session(&gorm.Session{}).Model(&items).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	order := s.order(spec)
+	signature := sortSignature(order)
+
+	query := filtered
+	if spec.Cursor != "" {
+		values, err := s.decodeCursor(spec.Cursor, order, signature)
+		if err != nil {
+			return nil, err
+		}
+		condition, args := s.after(order, values)
+		query = query.Where(condition, args...)
+	} else if spec.Offset > 0 {
+		query = query.Offset(spec.Offset)
+	}
+	for _, sort := range order {
+		direction := "ASC"
+		if sort.Desc {
+			direction = "DESC"
+		}
+		query = query.Order(fmt.Sprintf("%s %s", s.column(sort.Field), direction))
+	}
+
+	// One more row than requested tells whether there is a next page
+	if err := query.Limit(spec.Limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) > spec.Limit {
+		items = items[:spec.Limit]
+		next, err := s.encodeCursor(db, items[len(items)-1], order, signature)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+	page.Items = items
+
+	return page, nil
+}
+
+// where adds the condition of a filter
+func (s *Schema) where(db *gorm.DB, filter Filter) (*gorm.DB, error) {
+	column := s.column(filter.Field)
+	if normalize := s.Fields[filter.Field].Normalize; normalize != nil {
+		if value, ok := filter.Value.(string); ok {
+			filter.Value = normalize(value)
+		}
+	}
+	switch filter.Operator {
+	case Eq:
+		return db.Where(fmt.Sprintf("%s = ?", column), filter.Value), nil
+	case Gt:
+		return db.Where(fmt.Sprintf("%s > ?", column), filter.Value), nil
+	case Gte:
+		return db.Where(fmt.Sprintf("%s >= ?", column), filter.Value), nil
+	case Lt:
+		return db.Where(fmt.Sprintf("%s < ?", column), filter.Value), nil
+	case Lte:
+		return db.Where(fmt.Sprintf("%s <= ?", column), filter.Value), nil
+	case Contains:
+		// ! escapes the wildcards in the value on every dialect
+		pattern := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(strings.ToLower(fmt.Sprint(filter.Value)))
+		return db.Where(fmt.Sprintf("LOWER(%s) LIKE ? ESCAPE '!'", column), "%"+pattern+"%"), nil
+	default:
+		return nil, &Error{Problems: []string{fmt.Sprintf("unknown operator %q", filter.Operator)}}
+	}
+}
+
+// after returns the condition selecting the rows following values in the
+// given order: a > x OR (a = x AND b > y) OR ..., with < for descending
+// fields
+func (s *Schema) after(order []Sort, values []interface{}) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+	for i, sort := range order {
+		var terms []string
+		for j := 0; j < i; j++ {
+			terms = append(terms, fmt.Sprintf("%s = ?", s.column(order[j].Field)))
+			args = append(args, values[j])
+		}
+		comparison := ">"
+		if sort.Desc {
+			comparison = "<"
+		}
+		terms = append(terms, fmt.Sprintf("%s %s ?", s.column(sort.Field), comparison))
+		args = append(args, values[i])
+		clauses = append(clauses, "("+strings.Join(terms, " AND ")+")")
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+func (s *Schema) encodeCursor(db *gorm.DB, item interface{}, order []Sort, signature string) (string, error) {
+	parsed, err := schema.Parse(item, &schemas, db.NamingStrategy)
+	if err != nil {
+		return "", err
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(item))
+	c := cursor{Sort: signature}
+	for _, sort := range order {
+		field := parsed.LookUpField(s.column(sort.Field))
+		if field == nil {
+			return "", fmt.Errorf("query: %s has no column %s", parsed.Name, s.column(sort.Field))
+		}
+		fieldValue, _ := field.ValueOf(context.Background(), value)
+		encoded, err := json.Marshal(fieldValue)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, encoded)
+	}
+
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+func (s *Schema) decodeCursor(encoded string, order []Sort, signature string) ([]interface{}, error) {
+	invalid := &Error{Problems: []string{"invalid cursor"}}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, invalid
+	}
+	if c.Sort != signature {
+		return nil, &Error{Problems: []string{"the cursor belongs to another sort order"}}
+	}
+	if len(c.Values) != len(order) {
+		return nil, invalid
+	}
+
+	values := make([]interface{}, len(order))
+	for i, sort := range order {
+		var err error
+		switch s.Fields[sort.Field].Kind {
+		case Int:
+			var n int64
+			err = json.Unmarshal(c.Values[i], &n)
+			values[i] = n
+		case Time:
+			var t time.Time
+			err = json.Unmarshal(c.Values[i], &t)
+			values[i] = t
+		case Bool:
+			var b bool
+			err = json.Unmarshal(c.Values[i], &b)
+			values[i] = b
+		default:
+			var str string
+			err = json.Unmarshal(c.Values[i], &str)
+			values[i] = str
+		}
+		if err != nil {
+			return nil, invalid
+		}
+	}
+	return values, nil
+}
+
+// sortSignature identifies an order, e.g. "-created_at,id"
+func sortSignature(order []Sort) string {
+	names := make([]string, len(order))
+	for i, sort := range order {
+		names[i] = sort.Field
+		if sort.Desc {
+			names[i] = "-" + sort.Field
+		}
+	}
+	return strings.Join(names, ",")
+}
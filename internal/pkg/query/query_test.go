@@ -0,0 +1,222 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/database"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	Email     string
+	Score     int
+	CreatedAt time.Time
+}
+
+var testSchema = &Schema{
+	Fields: map[string]Field{
+		"id":         {Kind: Int, Operators: []string{Eq}, Sortable: true},
+		"name":       {Kind: String, Operators: []string{Eq, Contains}, Sortable: true},
+		"email":      {Kind: String, Operators: []string{Eq}, Normalize: strings.ToLower},
+		"score":      {Kind: Int, Sortable: true},
+		"created_at": {Kind: Time, Operators: []string{Gte, Lt}, Sortable: true},
+	},
+	DefaultLimit: 2,
+	MaxLimit:     10,
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	model := &database.DBModel{Driver: "sqlite", Name: database.SQLITE_MEMORY}
+	db, err := model.OpenDB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+
+	if err := db.AutoMigrate(&item{}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 7; i++ {
+		db.Create(&item{
+			Name:      fmt.Sprintf("user_%d", i),
+			Email:     fmt.Sprintf("user%d@example.com", i),
+			Score:     i % 3,
+			CreatedAt: start.AddDate(0, 0, i),
+		})
+	}
+	return db
+}
+
+func TestParse(t *testing.T) {
+	spec, err := testSchema.Parse(url.Values{
+		"limit":           {"5"},
+		"sort":            {"-created_at,name"},
+		"name[contains]":  {"bob"},
+		"email":           {"bob@example.com"},
+		"created_at[gte]": {"2024-01-02"},
+		"created_at[lt]":  {"2024-02-01T00:00:00Z"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spec.Limit != 5 || len(spec.Filters) != 4 {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+	if len(spec.Sort) != 2 || spec.Sort[0] != (Sort{Field: "created_at", Desc: true}) || spec.Sort[1] != (Sort{Field: "name"}) {
+		t.Errorf("unexpected sort %+v", spec.Sort)
+	}
+
+	_, err = testSchema.Parse(url.Values{
+		"limit":          {"50"},
+		"password":       {"x"},
+		"email[gt]":      {"a"},
+		"sort":           {"email"},
+		"created_at[lt]": {"yesterday"},
+		"offset":         {"4"},
+		"cursor":         {"abc"},
+	})
+	var queryErr *Error
+	if !errors.As(err, &queryErr) {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	for _, want := range []string{
+		`unknown parameter "password"`,
+		"created_at[lt] must be a date",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q: %v", want, err)
+		}
+	}
+
+	// Spec problems are reported once the parameters are valid
+	_, err = testSchema.Parse(url.Values{"limit": {"50"}, "email[gt]": {"a"}, "sort": {"email"}, "offset": {"4"}, "cursor": {"abc"}})
+	for _, want := range []string{
+		"limit must be between 1 and 10",
+		`email does not support the "gt" operator`,
+		`cannot sort on "email"`,
+		"offset and cursor cannot be used together",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q: %v", want, err)
+		}
+	}
+}
+
+func TestFindWithOffset(t *testing.T) {
+	db := openTestDB(t)
+
+	spec := &Spec{Offset: 2, Sort: []Sort{{Field: "created_at", Desc: true}}}
+	page, err := Find[*item](db, testSchema, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 7 || page.Limit != 2 || page.Offset != 2 {
+		t.Errorf("unexpected metadata %+v", page)
+	}
+	if len(page.Items) != 2 || page.Items[0].Name != "user_5" || page.Items[1].Name != "user_4" {
+		t.Errorf("unexpected items %+v", page.Items)
+	}
+	if page.NextCursor == "" {
+		t.Error("expected a next cursor")
+	}
+}
+
+func TestFindWithFilters(t *testing.T) {
+	db := openTestDB(t)
+
+	spec, err := testSchema.Parse(url.Values{
+		"name[contains]":  {"USER_"},
+		"created_at[gte]": {"2024-01-03"},
+		"created_at[lt]":  {"2024-01-06"},
+		"limit":           {"10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := Find[item](db, testSchema, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Items) != 3 || page.NextCursor != "" {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	// Wildcards in the value match literally
+	page, err = Find[item](db, testSchema, &Spec{Filters: []Filter{{Field: "name", Operator: Contains, Value: "r%1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 0 {
+		t.Errorf("expected %% to match literally, got %d items", page.Total)
+	}
+
+	// Values are normalized like the stored emails
+	spec, err = testSchema.Parse(url.Values{"email": {"User3@Example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err = Find[item](db, testSchema, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Items[0].Email != "user3@example.com" {
+		t.Errorf("expected the email filter to ignore case, got %+v", page)
+	}
+}
+
+func TestFindWithCursor(t *testing.T) {
+	db := openTestDB(t)
+
+	// Scores repeat, so the order relies on the name and the key
+	sort := []Sort{{Field: "score", Desc: true}, {Field: "name"}}
+
+	var names []string
+	spec := &Spec{Limit: 3, Sort: sort}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("cursor does not advance")
+		}
+		page, err := Find[*item](db, testSchema, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 7 {
+			t.Errorf("expected the total of every page to be 7, got %d", page.Total)
+		}
+		for _, item := range page.Items {
+			names = append(names, item.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		spec = &Spec{Limit: 3, Sort: sort, Cursor: page.NextCursor}
+	}
+
+	want := "user_2,user_5,user_1,user_4,user_7,user_3,user_6"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	first, _ := Find[*item](db, testSchema, &Spec{Sort: sort})
+	_, err := Find[*item](db, testSchema, &Spec{Cursor: first.NextCursor})
+	if err == nil || !strings.Contains(err.Error(), "another sort order") {
+		t.Errorf("expected a sort mismatch error, got %v", err)
+	}
+	_, err = Find[*item](db, testSchema, &Spec{Cursor: "garbage"})
+	if err == nil || !strings.Contains(err.Error(), "invalid cursor") {
+		t.Errorf("expected an invalid cursor error, got %v", err)
+	}
+}
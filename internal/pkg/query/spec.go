@@ -0,0 +1,302 @@
+package query
+
+import (
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter operators
+const (
+	Eq       = "eq"
+	Contains = "contains"
+	Gt       = "gt"
+	Gte      = "gte"
+	Lt       = "lt"
+	Lte      = "lte"
+)
+
+// Kind is the type of a field's values
+type Kind int
+
+// Kinds
+const (
+	String Kind = iota
+	Int
+	Time
+	Bool
+)
+
+// Field is a field clients may filter or sort on
+type Field struct {
+	// Column is the database column, the field name when empty
+	Column string
+	Kind   Kind
+	// Operators are the filter operators allowed on the field, none when
+	// it can only be sorted on
+	Operators []string
+	Sortable  bool
+	// Normalize rewrites the string values the field is filtered on, for
+	// columns stored in a canonical form, e.g. emails in lower case
+	Normalize func(string) string
+}
+
+// Schema whitelists the fields of a resource that can be filtered and
+// sorted on, by the names used in query strings
+type Schema struct {
+	Fields map[string]Field
+	// Key is the unique field ending every sort so rows have a stable
+	// order, "id" when empty. It must be in Fields.
+	Key string
+	// DefaultSort applies when a spec has no sort, Key ascending when empty
+	DefaultSort []Sort
+	// DefaultLimit and MaxLimit bound the page size, 20 and 100 when zero
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Filter restricts the results to rows whose field compares to Value
+type Filter struct {
+	Field    string
+	Operator string
+	Value    interface{}
+}
+
+// Sort orders the results by a field
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Spec describes a page of results: filters, sort order and either an
+// offset or the cursor of the previous page
+type Spec struct {
+	Filters []Filter
+	Sort    []Sort
+	Limit   int
+	Offset  int
+	Cursor  string
+}
+
+// Error lists every problem found in a query string or spec
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid query: " + strings.Join(e.Problems, "; ")
+}
+
+// ErrorKind implements errs.Kinded.
+func (e *Error) ErrorKind() errs.Kind {
+	return errs.Validation
+}
+
+// filterKey matches "name" and "name[operator]"
+var filterKey = regexp.MustCompile(`^([a-z0-9_]+)(?:\[([a-z]+)\])?$`)
+
+// Parse reads a spec from query string values:
+//
+//	?limit=20&offset=40
+//	?limit=20&cursor=<next_cursor of the previous page>
+//	?sort=-created_at,name
+//	?name[contains]=bob&email=bob@example.com&created_at[gte]=2024-01-01
+//
+// A filter without an operator compares for equality. Unknown parameters,
+// fields and operators are rejected; every problem is reported in an
+// *Error.
+func (s *Schema) Parse(values url.Values) (*Spec, error) {
+	spec := &Spec{}
+	var problems []string
+
+	for key, list := range values {
+		if len(list) == 0 {
+			continue
+		}
+		value := list[len(list)-1]
+
+		switch key {
+		case "limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("limit must be a number, got %q", value))
+			}
+			spec.Limit = limit
+			continue
+		case "offset":
+			offset, err := strconv.Atoi(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("offset must be a number, got %q", value))
+			}
+			spec.Offset = offset
+			continue
+		case "cursor":
+			spec.Cursor = value
+			continue
+		case "sort":
+			for _, name := range strings.Split(value, ",") {
+				name = strings.TrimSpace(name)
+				if name == "" {
+					continue
+				}
+				desc := strings.HasPrefix(name, "-")
+				spec.Sort = append(spec.Sort, Sort{Field: strings.TrimPrefix(name, "-"), Desc: desc})
+			}
+			continue
+		}
+
+		match := filterKey.FindStringSubmatch(key)
+		if match == nil {
+			problems = append(problems, fmt.Sprintf("unknown parameter %q", key))
+			continue
+		}
+		operator := match[2]
+		if operator == "" {
+			operator = Eq
+		}
+
+		field, ok := s.Fields[match[1]]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown parameter %q", key))
+			continue
+		}
+		parsed, err := parseValue(field.Kind, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %v", key, err))
+			continue
+		}
+		spec.Filters = append(spec.Filters, Filter{Field: match[1], Operator: operator, Value: parsed})
+	}
+
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+	if err := s.Validate(spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Validate checks a spec against the schema and applies the default limit
+func (s *Schema) Validate(spec *Spec) error {
+	var problems []string
+
+	for _, filter := range spec.Filters {
+		field, ok := s.Fields[filter.Field]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("cannot filter on %q", filter.Field))
+			continue
+		}
+		if !contains(field.Operators, filter.Operator) {
+			problems = append(problems, fmt.Sprintf("%s does not support the %q operator", filter.Field, filter.Operator))
+		}
+	}
+
+	for _, sort := range spec.Sort {
+		if field, ok := s.Fields[sort.Field]; !ok || !field.Sortable {
+			problems = append(problems, fmt.Sprintf("cannot sort on %q", sort.Field))
+		}
+	}
+
+	maxLimit := s.MaxLimit
+	if maxLimit == 0 {
+		maxLimit = 100
+	}
+	switch {
+	case spec.Limit == 0:
+		spec.Limit = s.DefaultLimit
+		if spec.Limit == 0 {
+			spec.Limit = 20
+		}
+	case spec.Limit < 0 || spec.Limit > maxLimit:
+		problems = append(problems, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+	}
+
+	if spec.Offset < 0 {
+		problems = append(problems, "offset must not be negative")
+	}
+	if spec.Offset > 0 && spec.Cursor != "" {
+		problems = append(problems, "offset and cursor cannot be used together")
+	}
+
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+// key returns the name of the key field
+func (s *Schema) key() string {
+	if s.Key == "" {
+		return "id"
+	}
+	return s.Key
+}
+
+// column returns the database column of a field
+func (s *Schema) column(name string) string {
+	if column := s.Fields[name].Column; column != "" {
+		return column
+	}
+	return name
+}
+
+// order returns the sort of spec, or the default one, ending with the key
+func (s *Schema) order(spec *Spec) []Sort {
+	sorts := spec.Sort
+	if len(sorts) == 0 {
+		sorts = s.DefaultSort
+	}
+
+	order := make([]Sort, 0, len(sorts)+1)
+	for _, sort := range sorts {
+		order = append(order, sort)
+		if sort.Field == s.key() {
+			return order
+		}
+	}
+	return append(order, Sort{Field: s.key()})
+}
+
+// parseValue converts a query string value to the field's kind. Times are
+// RFC 3339 timestamps or dates.
+func parseValue(kind Kind, value string) (interface{}, error) {
+	switch kind {
+	case Int:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number, got %q", value)
+		}
+		return n, nil
+	case Time:
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, fmt.Errorf("must be a date or an RFC 3339 time, got %q", value)
+		}
+		return t, nil
+	case Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false, got %q", value)
+		}
+		return b, nil
+	default:
+		return value, nil
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package rbac
+
+import (
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/auth"
+
+	"github.com/labstack/echo"
+)
+
+// Require returns a guard rejecting requests whose token subject does not
+// hold every one of the permissions with ErrPermissionDenied. It must run
+// after the authenticator's middleware; requests without claims are
+// rejected with auth.ErrMissingToken.
+func (r *RBAC) Require(permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := auth.FromEcho(c)
+			if !ok {
+				return auth.ErrMissingToken
+			}
+
+			allowed, err := r.Can(c.Request().Context(), claims.Subject, permissions...)
+			if err != nil {
+				return fmt.Errorf("check permissions: %w", err)
+			}
+			if !allowed {
+				return ErrPermissionDenied
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireSelfOr returns a guard letting requests through when the path
+// parameter param is their token subject, e.g. users changing their own
+// account, and otherwise requiring every one of the permissions like Require
+func (r *RBAC) RequireSelfOr(param string, permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := auth.FromEcho(c)
+			if !ok {
+				return auth.ErrMissingToken
+			}
+			if claims.Subject != "" && claims.Subject == c.Param(param) {
+				return next(c)
+			}
+			return r.Require(permissions...)(next)(c)
+		}
+	}
+}
+
+// Require is Require of the application's RBAC. The RBAC is looked up when a
+// request is checked, so routes can be registered before it is set.
+func Require(permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return Default().Require(permissions...)(next)(c)
+		}
+	}
+}
+
+// RequireSelfOr is RequireSelfOr of the application's RBAC, looked up when a
+// request is checked
+func RequireSelfOr(param string, permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return Default().RequireSelfOr(param, permissions...)(next)(c)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package rbac
+
+import (
+	"go-modular-boilerplate/internal/pkg/migration"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migrations returns the migrations creating the roles tables
+func Migrations() []migration.Migration {
+	return []migration.Migration{
+		{
+			Version: 1,
+			Name:    "create_roles",
+			Up: func(tx *gorm.DB) error {
+				return tx.Migrator().CreateTable(&roleV1{}, &rolePermissionV1{}, &roleAssignmentV1{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&roleAssignmentV1{}, &rolePermissionV1{}, &roleV1{})
+			},
+		},
+	}
+}
+
+// roleV1, rolePermissionV1 and roleAssignmentV1 are the tables as created by
+// the first migration
+type roleV1 struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"size:100;uniqueIndex"`
+	Description string `gorm:"size:255"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (roleV1) TableName() string {
+	return "roles"
+}
+
+type rolePermissionV1 struct {
+	RoleID     uint   `gorm:"primaryKey;autoIncrement:false"`
+	Permission string `gorm:"primaryKey;size:100"`
+}
+
+func (rolePermissionV1) TableName() string {
+	return "role_permissions"
+}
+
+type roleAssignmentV1 struct {
+	Subject string `gorm:"primaryKey;size:64"`
+	RoleID  uint   `gorm:"primaryKey;autoIncrement:false;index"`
+}
+
+func (roleAssignmentV1) TableName() string {
+	return "role_assignments"
+}
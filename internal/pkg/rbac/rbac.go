@@ -0,0 +1,276 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// All is the permission granting every other permission, held by the admin
+// role
+const All = "*"
+
+// Errors
+var (
+	ErrUnknownPermission = errs.New(errs.Validation, "unknown_permission", "unknown permission")
+	ErrRoleNotFound      = errs.New(errs.NotFound, "role_not_found", "role not found")
+	ErrPermissionDenied  = errs.New(errs.Forbidden, "permission_denied", "permission denied")
+)
+
+// Permission is an action a module lets roles perform, named
+// "<resource>:<action>" by convention, e.g. users:delete
+type Permission struct {
+	Name        string
+	Description string
+}
+
+// role is a named set of permissions
+type role struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"size:100;uniqueIndex"`
+	Description string `gorm:"size:255"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (role) TableName() string {
+	return "roles"
+}
+
+// rolePermission grants a permission to a role
+type rolePermission struct {
+	RoleID     uint   `gorm:"primaryKey;autoIncrement:false"`
+	Permission string `gorm:"primaryKey;size:100"`
+}
+
+func (rolePermission) TableName() string {
+	return "role_permissions"
+}
+
+// roleAssignment gives a role to a subject, the sub claim of its tokens
+type roleAssignment struct {
+	Subject string `gorm:"primaryKey;size:64"`
+	RoleID  uint   `gorm:"primaryKey;autoIncrement:false"`
+}
+
+func (roleAssignment) TableName() string {
+	return "role_assignments"
+}
+
+// RBAC checks the permissions of token subjects against the roles stored in
+// the database. Permissions are declared by the modules; roles, the
+// permissions granted to them and their assignments are stored.
+type RBAC struct {
+	mu          sync.RWMutex
+	permissions map[string]Permission
+	modules     map[string]string
+}
+
+// New creates an RBAC without permissions
+func New() *RBAC {
+	return &RBAC{
+		permissions: make(map[string]Permission),
+		modules:     make(map[string]string),
+	}
+}
+
+// Register declares the permissions of a module. A permission can only be
+// declared by one module.
+func (r *RBAC) Register(module string, permissions ...Permission) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, permission := range permissions {
+		if permission.Name == "" || permission.Name == All {
+			return fmt.Errorf("module %s: invalid permission name %q", module, permission.Name)
+		}
+		if owner, exists := r.modules[permission.Name]; exists && owner != module {
+			return fmt.Errorf("module %s: permission %s is already declared by module %s", module, permission.Name, owner)
+		}
+		r.permissions[permission.Name] = permission
+		r.modules[permission.Name] = module
+	}
+	return nil
+}
+
+// Permissions returns the declared permissions sorted by name
+func (r *RBAC) Permissions() []Permission {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	permissions := make([]Permission, 0, len(r.permissions))
+	for _, permission := range r.permissions {
+		permissions = append(permissions, permission)
+	}
+	sort.Slice(permissions, func(i, j int) bool {
+		return permissions[i].Name < permissions[j].Name
+	})
+	return permissions
+}
+
+func (r *RBAC) declared(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.permissions[name]
+	return ok || name == All
+}
+
+// CreateRole creates a role, or updates the description of an existing one
+func (r *RBAC) CreateRole(ctx context.Context, name, description string) error {
+	return database.Conn(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"description", "updated_at"}),
+	}).Create(&role{Name: name, Description: description}).Error
+}
+
+// Grant gives permissions to a role. Every permission must have been
+// declared by a module.
+func (r *RBAC) Grant(ctx context.Context, roleName string, permissions ...string) error {
+	for _, permission := range permissions {
+		if !r.declared(permission) {
+			return fmt.Errorf("%w: %s", ErrUnknownPermission, permission)
+		}
+	}
+
+	id, err := r.roleID(ctx, roleName)
+	if err != nil {
+		return err
+	}
+
+	grants := make([]rolePermission, 0, len(permissions))
+	for _, permission := range permissions {
+		grants = append(grants, rolePermission{RoleID: id, Permission: permission})
+	}
+	if len(grants) == 0 {
+		return nil
+	}
+	return database.Conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&grants).Error
+}
+
+// Revoke takes permissions away from a role
+func (r *RBAC) Revoke(ctx context.Context, roleName string, permissions ...string) error {
+	id, err := r.roleID(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	return database.Conn(ctx).Where("role_id = ? AND permission IN ?", id, permissions).
+		Delete(&rolePermission{}).Error
+}
+
+// Assign gives a role to a subject
+func (r *RBAC) Assign(ctx context.Context, subject, roleName string) error {
+	id, err := r.roleID(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	return database.Conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&roleAssignment{Subject: subject, RoleID: id}).Error
+}
+
+// Unassign takes a role away from a subject
+func (r *RBAC) Unassign(ctx context.Context, subject, roleName string) error {
+	id, err := r.roleID(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	return database.Conn(ctx).Where("subject = ? AND role_id = ?", subject, id).
+		Delete(&roleAssignment{}).Error
+}
+
+// Roles returns the names of the roles of a subject
+func (r *RBAC) Roles(ctx context.Context, subject string) ([]string, error) {
+	var names []string
+	err := database.Conn(ctx).Model(&role{}).
+		Joins("JOIN role_assignments ON role_assignments.role_id = roles.id").
+		Where("role_assignments.subject = ?", subject).
+		Order("roles.name").
+		Pluck("roles.name", &names).Error
+	return names, err
+}
+
+// SubjectPermissions returns the permissions granted to a subject through
+// its roles
+func (r *RBAC) SubjectPermissions(ctx context.Context, subject string) ([]string, error) {
+	var permissions []string
+	err := database.Conn(ctx).Model(&rolePermission{}).
+		Distinct("role_permissions.permission").
+		Joins("JOIN role_assignments ON role_assignments.role_id = role_permissions.role_id").
+		Where("role_assignments.subject = ?", subject).
+		Pluck("role_permissions.permission", &permissions).Error
+	return permissions, err
+}
+
+// Can reports whether a subject holds every one of the permissions
+func (r *RBAC) Can(ctx context.Context, subject string, permissions ...string) (bool, error) {
+	granted, err := r.SubjectPermissions(ctx, subject)
+	if err != nil {
+		return false, err
+	}
+
+	held := make(map[string]struct{}, len(granted))
+	for _, permission := range granted {
+		if permission == All {
+			return true, nil
+		}
+		held[permission] = struct{}{}
+	}
+	for _, permission := range permissions {
+		if _, ok := held[permission]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Bootstrap creates the admin role holding every permission and assigns it
+// to the given subjects. Subjects are never unassigned, so removing one from
+// the configuration does not take its role away.
+func (r *RBAC) Bootstrap(ctx context.Context, adminRole string, subjects ...string) error {
+	return database.Transaction(ctx, func(ctx context.Context) error {
+		if err := r.CreateRole(ctx, adminRole, "Every permission"); err != nil {
+			return err
+		}
+		if err := r.Grant(ctx, adminRole, All); err != nil {
+			return err
+		}
+		for _, subject := range subjects {
+			if err := r.Assign(ctx, subject, adminRole); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *RBAC) roleID(ctx context.Context, name string) (uint, error) {
+	var found role
+	err := database.Conn(database.WithPrimary(ctx)).Where("name = ?", name).First(&found).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+		}
+		return 0, err
+	}
+	return found.ID, nil
+}
+
+// defaultRBAC is the application's RBAC, set by the application
+var defaultRBAC *RBAC
+
+// SetDefault sets the RBAC returned by Default
+func SetDefault(r *RBAC) {
+	defaultRBAC = r
+}
+
+// Default returns the application's RBAC
+func Default() *RBAC {
+	return defaultRBAC
+}
@@ -0,0 +1,194 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"go-modular-boilerplate/internal/pkg/auth"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/jwt"
+	"go-modular-boilerplate/internal/pkg/problem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func newTestRBAC(t *testing.T) *RBAC {
+	t.Helper()
+
+	model := &database.DBModel{Driver: "sqlite", Name: database.SQLITE_MEMORY}
+	db, err := model.OpenDB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := Migrations()[0].Up(db); err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+
+	r := New()
+	if err := r.Register("orders",
+		Permission{Name: "orders:read"},
+		Permission{Name: "orders:delete"},
+	); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	r := New()
+	if err := r.Register("orders", Permission{Name: "orders:read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("billing", Permission{Name: "orders:read"}); err == nil {
+		t.Error("expected an error for a permission declared by two modules")
+	}
+	if err := r.Register("billing", Permission{Name: All}); err == nil {
+		t.Error("expected an error for the wildcard permission")
+	}
+}
+
+func TestCan(t *testing.T) {
+	r := newTestRBAC(t)
+	ctx := context.Background()
+
+	if err := r.CreateRole(ctx, "support", "Reads orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Grant(ctx, "support", "orders:read"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Grant(ctx, "support", "orders:refund"); !errors.Is(err, ErrUnknownPermission) {
+		t.Errorf("expected ErrUnknownPermission, got %v", err)
+	}
+	if err := r.Assign(ctx, "7", "missing"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("expected ErrRoleNotFound, got %v", err)
+	}
+	if err := r.Assign(ctx, "7", "support"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Bootstrap(ctx, "admin", "1"); err != nil {
+		t.Fatal(err)
+	}
+	// Bootstrapping again on the next start is a no-op
+	if err := r.Bootstrap(ctx, "admin", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		subject     string
+		permissions []string
+		want        bool
+	}{
+		{subject: "7", permissions: []string{"orders:read"}, want: true},
+		{subject: "7", permissions: []string{"orders:read", "orders:delete"}, want: false},
+		{subject: "1", permissions: []string{"orders:read", "orders:delete"}, want: true},
+		{subject: "2", permissions: []string{"orders:read"}, want: false},
+	}
+	for _, tt := range tests {
+		got, err := r.Can(ctx, tt.subject, tt.permissions...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Can(%s, %v) = %v, want %v", tt.subject, tt.permissions, got, tt.want)
+		}
+	}
+
+	if err := r.Unassign(ctx, "7", "support"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := r.Can(ctx, "7", "orders:read"); ok {
+		t.Error("expected the permission to be gone with the role")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	r := newTestRBAC(t)
+	if err := r.Bootstrap(context.Background(), "admin", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &jwt.JWTImpl{SignatureKey: "secret", TTL: time.Minute}
+	authenticator := auth.NewAuthenticator(auth.Config{JWT: issuer})
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.Handler(problem.Config{})
+	e.DELETE("/orders/:id", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, authenticator.Middleware(), r.Require("orders:delete"))
+	e.GET("/open", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, r.Require("orders:read"))
+
+	request := func(method, path, subject string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if subject != "" {
+			token, err := issuer.GenerateToken(jwt.Claims{Subject: subject})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := request(http.MethodDelete, "/orders/1", "1"); code != http.StatusNoContent {
+		t.Errorf("expected the admin to be allowed, got %d", code)
+	}
+	if code := request(http.MethodDelete, "/orders/1", "2"); code != http.StatusForbidden {
+		t.Errorf("expected 403 without the permission, got %d", code)
+	}
+	if code := request(http.MethodGet, "/open", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without an authenticator, got %d", code)
+	}
+}
+
+func TestRequireSelfOr(t *testing.T) {
+	r := newTestRBAC(t)
+	if err := r.Bootstrap(context.Background(), "admin", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &jwt.JWTImpl{SignatureKey: "secret", TTL: time.Minute}
+	authenticator := auth.NewAuthenticator(auth.Config{JWT: issuer})
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.Handler(problem.Config{})
+	e.PATCH("/users/:id", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, authenticator.Middleware(), r.RequireSelfOr("id", "orders:delete"))
+
+	patch := func(path, subject string) int {
+		req := httptest.NewRequest(http.MethodPatch, path, nil)
+		token, err := issuer.GenerateToken(jwt.Claims{Subject: subject})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := patch("/users/2", "2"); code != http.StatusOK {
+		t.Errorf("expected a user to be allowed to patch themselves, got %d", code)
+	}
+	if code := patch("/users/3", "2"); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a user patching another user, got %d", code)
+	}
+	if code := patch("/users/3", "1"); code != http.StatusOK {
+		t.Errorf("expected the admin to be allowed, got %d", code)
+	}
+}
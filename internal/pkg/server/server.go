@@ -2,38 +2,55 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"go-modular-boilerplate/internal/pkg/logger"
 	"log"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
+	"sync"
 	"time"
 )
 
 type IServer interface {
-	Run()
-	RunWithSSL()
+	Run() error
+	RunWithSSL() error
+	Shutdown(ctx context.Context) error
 }
 
 type ServerContext struct {
 	Handler http.Handler
 	Host    string
 
-	CertFile interface{}
-	KeyFile  interface{}
+	CertFile string
+	KeyFile  string
+	TLS      TLSConfig
+
+	// Logger receives the certificate reloader's messages, the default
+	// logger when nil
+	Logger *logger.Logger
 
 	Timeout      time.Duration
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+
+	mu       sync.Mutex
+	server   *http.Server
+	reloader *certReloader
+	// closed is set by Shutdown so a server that has not started
+	// listening yet never does
+	closed bool
 }
 
-func NewServer(s ServerContext) IServer {
-	return ServerContext{
+func NewServer(s *ServerContext) IServer {
+	return &ServerContext{
+		Handler:      s.Handler,
 		Host:         s.Host,
 		CertFile:     s.CertFile,
 		KeyFile:      s.KeyFile,
+		TLS:          s.TLS,
+		Logger:       s.Logger,
 		Timeout:      s.Timeout,
 		ReadTimeout:  s.ReadTimeout,
 		WriteTimeout: s.WriteTimeout,
@@ -41,68 +58,113 @@ func NewServer(s ServerContext) IServer {
 	}
 }
 
-func (s ServerContext) Run() {
-	// Set up a channel to listen to for interrupt signals
-	var runChan = make(chan os.Signal, 1)
+// Run serves HTTP until the server fails or Shutdown is called. It returns
+// nil after a clean shutdown.
+func (s *ServerContext) Run() error {
+	return s.serve(nil)
+}
+
+// RunWithSSL serves HTTPS using CertFile/KeyFile and the TLS settings. The
+// certificate is reloaded when the files change or on SIGHUP.
+func (s *ServerContext) RunWithSSL() error {
+	reloaderLog := s.Logger
+	if reloaderLog == nil {
+		reloaderLog = logger.Default()
+	}
+	reloader, err := newCertReloader(s.CertFile, s.KeyFile, reloaderLog)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := buildTLSConfig(s.TLS, reloader)
+	if err != nil {
+		reloader.Close()
+		return err
+	}
 
-	// Set up a context to allow for graceful server shutdowns in the event
-	// of an OS interrupt (defers the cancel just in case)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		reloader.Close()
+		return nil
+	}
+	s.reloader = reloader
+	s.mu.Unlock()
 
-	ctx, cancel := context.WithTimeout(
-		context.Background(),
-		s.Timeout,
-	)
-	defer cancel()
+	return s.serve(tlsConfig)
+}
 
+func (s *ServerContext) serve(tlsConfig *tls.Config) error {
 	// Define server options
 	server := &http.Server{
 		Addr:         s.Host,
 		Handler:      s.Handler,
-		ReadTimeout:  s.Timeout * time.Second,
-		WriteTimeout: s.WriteTimeout * time.Second,
-		IdleTimeout:  s.IdleTimeout * time.Second,
+		TLSConfig:    tlsConfig,
+		ReadTimeout:  s.ReadTimeout,
+		WriteTimeout: s.WriteTimeout,
+		IdleTimeout:  s.IdleTimeout,
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
 	}
+	s.server = server
+	s.mu.Unlock()
 
 	fmt.Println(`
-	.___        _____                  ________        
-	|   | _____/ ____\___________     /  _____/  ____  
-	|   |/    \   __\\_  __ \__  \   /   \  ___ /  _ \ 
+	.___        _____                  ________
+	|   | _____/ ____\___________     /  _____/  ____
+	|   |/    \   __\\_  __ \__  \   /   \  ___ /  _ \
 	|   |   |  \  |   |  | \// __ \_ \    \_\  (  <_> )
-	|___|___|  /__|   |__|  (____  /  \______  /\____/ 
-			 \/                  \/          \/        
+	|___|___|  /__|   |__|  (____  /  \______  /\____/
+			 \/                  \/          \/
 
 		- Simple Boilerplate made easy -
-	
+
 	`)
 
-	// info
-	log.Printf("Server Running on : %v", s.Host)
+	var err error
+	if tlsConfig != nil {
+		log.Printf("Server Running on : %v (TLS)", s.Host)
+		// The certificate comes from TLSConfig.GetCertificate
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		log.Printf("Server Running on : %v", s.Host)
+		err = server.ListenAndServe()
+	}
 
-	// Handle ctrl+c/ctrl+x interrupt
-	signal.Notify(runChan, os.Interrupt, syscall.SIGTERM)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed to start due to err: %w", err)
+	}
 
-	// Run the server on a new goroutine
-	go func() {
-		if err := server.ListenAndServe(); err != nil {
-			if err != http.ErrServerClosed {
-				log.Fatalf("Server failed to start due to err: %v", err)
-			}
-		}
-	}()
+	return nil
+}
 
-	// Block on this channel listeninf for those previously defined syscalls assign
-	// to variable so we can let the user know why the server is shutting down
-	interrupt := <-runChan
+// Shutdown stops accepting new connections and waits for in-flight requests
+// to complete until ctx is done. Called before the server has started, it
+// keeps Run and RunWithSSL from listening.
+func (s *ServerContext) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	server := s.server
+	reloader := s.reloader
+	s.mu.Unlock()
+
+	if reloader != nil {
+		defer reloader.Close()
+	}
 
-	// If we get one of the pre-prescribed syscalls, gracefully terminate the server
-	// while alerting the user
-	log.Fatalf("Server is shutting down due to %+v\n", interrupt)
+	if server == nil {
+		return nil
+	}
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server was unable to gracefully shutdown due to err: %+v", err)
+		// Drop the connections that did not finish in time
+		server.Close()
+		return fmt.Errorf("server was unable to gracefully shutdown due to err: %w", err)
 	}
-}
-
-func (s ServerContext) RunWithSSL() {
 
+	return nil
 }
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownBeforeRun(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := listener.Addr().String()
+	listener.Close()
+
+	s := NewServer(&ServerContext{Handler: http.NotFoundHandler(), Host: host})
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Run to return after Shutdown")
+	}
+
+	if conn, err := net.Dial("tcp", host); err == nil {
+		conn.Close()
+		t.Error("expected the server not to listen")
+	}
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/filewatch"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// TLSConfig holds the TLS settings of the server
+type TLSConfig struct {
+	// MinVersion is the minimum accepted protocol version: "1.2" or "1.3"
+	MinVersion string
+
+	// CipherSuites restricts the TLS 1.2 cipher suites, by their Go names
+	// (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256). Suites Go considers
+	// insecure are rejected and TLS 1.3 suites are not configurable. Empty
+	// means the Go defaults.
+	CipherSuites []string
+
+	// ClientCAFile is a PEM bundle used to verify client certificates
+	ClientCAFile string
+
+	// ClientAuth is the client certificate policy: "none", "request",
+	// "require", "verify_if_given" or "require_and_verify" (mTLS). With a
+	// ClientCAFile it must be one of the verifying policies.
+	ClientAuth string
+}
+
+// tlsVersions are the accepted minimum versions, TLS 1.0 and 1.1 are
+// deprecated (RFC 8996)
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                   tls.NoClientCert,
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// buildTLSConfig converts the server TLS settings into a *tls.Config whose
+// certificate is served by reloader
+func buildTLSConfig(cfg TLSConfig, reloader *certReloader) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if cfg.MinVersion != "" {
+		version, ok := tlsVersions[cfg.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS min version %q", cfg.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if len(cfg.CipherSuites) > 0 {
+		suites, err := parseCipherSuites(cfg.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.CipherSuites = suites
+	}
+
+	clientAuth, ok := clientAuthTypes[strings.ToLower(cfg.ClientAuth)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS client auth %q", cfg.ClientAuth)
+	}
+
+	if cfg.ClientCAFile != "" {
+		// A client CA bundle is only configured for mTLS, which must not be
+		// silently disabled by a policy that skips verification
+		if clientAuth != tls.VerifyClientCertIfGiven && clientAuth != tls.RequireAndVerifyClientCert {
+			return nil, fmt.Errorf("TLS client auth %q does not verify client certificates against the client CA file", cfg.ClientAuth)
+		}
+
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA bundle %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	} else if clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert {
+		return nil, errors.New("client certificate verification requires a client CA file")
+	}
+	tlsConfig.ClientAuth = clientAuth
+
+	return tlsConfig, nil
+}
+
+// parseCipherSuites maps cipher suite names to their IDs, rejecting the
+// insecure ones (RC4, 3DES, CBC-SHA256...)
+func parseCipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		if insecure[name] {
+			return nil, fmt.Errorf("insecure TLS cipher suite %q", name)
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// certReloader serves the current certificate and reloads it from disk when
+// the files change or the process receives SIGHUP. New handshakes pick up
+// the new certificate; established connections are left untouched.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      *logger.Logger
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+
+	watcher *filewatch.Watcher
+	signals chan os.Signal
+	done    chan struct{}
+	once    sync.Once
+}
+
+// newCertReloader loads the certificate and starts watching for changes
+func newCertReloader(certFile, keyFile string, log *logger.Logger) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		log:      log,
+		signals:  make(chan os.Signal, 1),
+		done:     make(chan struct{}),
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := filewatch.New(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("watching TLS certificate: %w", err)
+	}
+	r.watcher = watcher
+
+	signal.Notify(r.signals, syscall.SIGHUP)
+
+	go r.watch()
+
+	return r, nil
+}
+
+// reload reads the certificate and key from disk, keeping the current
+// certificate if they cannot be loaded
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *certReloader) watch() {
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-r.signals:
+			r.log.Info("Received SIGHUP, reloading TLS certificate")
+			r.reloadAndLog()
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if r.watcher.Relevant(event) {
+				r.reloadAndLog()
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.log.Error("TLS certificate watcher error", "error", err)
+		}
+	}
+}
+
+func (r *certReloader) reloadAndLog() {
+	if err := r.reload(); err != nil {
+		r.log.Error("Keeping current TLS certificate", "file", r.certFile, "error", err)
+		return
+	}
+	r.log.Info("TLS certificate reloaded", "file", r.certFile)
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Close stops watching for changes
+func (r *certReloader) Close() error {
+	var err error
+	r.once.Do(func() {
+		signal.Stop(r.signals)
+		close(r.done)
+		err = r.watcher.Close()
+	})
+	return err
+}
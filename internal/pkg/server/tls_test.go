@@ -0,0 +1,174 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for commonName to dir
+func writeCert(t *testing.T, dir, commonName string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func testLogger(t *testing.T) *logger.Logger {
+	t.Helper()
+	log, err := logger.NewLogger(logger.Config{Level: logger.ErrorLevel, OutputPath: filepath.Join(t.TempDir(), "test.log")}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log
+}
+
+func commonName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "ca")
+
+	reloader, err := newCertReloader(certFile, keyFile, testLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloader.Close()
+
+	cfg, err := buildTLSConfig(TLSConfig{
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+		ClientCAFile: certFile,
+		ClientAuth:   "require_and_verify",
+	}, reloader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.MinVersion != tls.VersionTLS13 {
+		t.Errorf("expected TLS 1.3 min version, got %x", cfg.MinVersion)
+	}
+	if len(cfg.CipherSuites) != 1 || cfg.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("unexpected cipher suites %v", cfg.CipherSuites)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert || cfg.ClientCAs == nil {
+		t.Errorf("expected mTLS with a client CA pool")
+	}
+
+	invalid := []TLSConfig{
+		{MinVersion: "0.9"},
+		{MinVersion: "1.0"},
+		{MinVersion: "1.1"},
+		{CipherSuites: []string{"TLS_NOPE"}},
+		{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_RC4_128_SHA"}},
+		{CipherSuites: []string{"TLS_RSA_WITH_3DES_EDE_CBC_SHA"}},
+		{ClientAuth: "sometimes"},
+		{ClientAuth: "require_and_verify"},
+		{ClientCAFile: certFile},
+		{ClientCAFile: certFile, ClientAuth: "none"},
+		{ClientCAFile: certFile, ClientAuth: "request"},
+		{ClientCAFile: certFile, ClientAuth: "require"},
+	}
+	for _, c := range invalid {
+		if _, err := buildTLSConfig(c, reloader); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestCertReloaderReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first")
+
+	reloader, err := newCertReloader(certFile, keyFile, testLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloader.Close()
+
+	if got := commonName(t, reloader); got != "first" {
+		t.Fatalf("expected first certificate, got %s", got)
+	}
+
+	writeCert(t, dir, "second")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for commonName(t, reloader) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not reloaded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCertReloaderKeepsCertificateOnInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first")
+
+	reloader, err := newCertReloader(certFile, keyFile, testLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloader.Close()
+
+	if err := os.WriteFile(certFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.reload(); err == nil {
+		t.Fatal("expected reload error")
+	}
+
+	if got := commonName(t, reloader); got != "first" {
+		t.Errorf("expected the previous certificate to be kept, got %s", got)
+	}
+}
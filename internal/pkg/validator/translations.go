@@ -0,0 +1,149 @@
+package validator
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator"
+)
+
+// invalid is the message of rules without one in a locale
+const invalid = "invalid"
+
+// sized are the rules on lengths, which have a message for strings
+// ("<rule>.string"), for lists and maps ("<rule>.items") and for numbers
+// ("<rule>.number")
+var sized = map[string]bool{
+	"min": true, "max": true, "len": true,
+	"gt": true, "gte": true, "lt": true, "lte": true,
+}
+
+// messages are the built-in messages by locale
+var messages = map[string]map[string]string{
+	"en": {
+		invalid:      "{0} is invalid",
+		"required":   "{0} is required",
+		"email":      "{0} must be a valid email address",
+		"url":        "{0} must be a valid URL",
+		"uuid":       "{0} must be a valid UUID",
+		"numeric":    "{0} must be a number",
+		"alphanum":   "{0} may only contain letters and numbers",
+		"oneof":      "{0} must be one of [{1}]",
+		"min.string": "{0} must be at least {1} characters long",
+		"min.items":  "{0} must contain at least {1} items",
+		"min.number": "{0} must be {1} or greater",
+		"max.string": "{0} must be at most {1} characters long",
+		"max.items":  "{0} must contain at most {1} items",
+		"max.number": "{0} must be {1} or less",
+		"len.string": "{0} must be {1} characters long",
+		"len.items":  "{0} must contain {1} items",
+		"len.number": "{0} must be equal to {1}",
+		"gt.string":  "{0} must be longer than {1} characters",
+		"gt.items":   "{0} must contain more than {1} items",
+		"gt.number":  "{0} must be greater than {1}",
+		"gte.string": "{0} must be at least {1} characters long",
+		"gte.items":  "{0} must contain at least {1} items",
+		"gte.number": "{0} must be {1} or greater",
+		"lt.string":  "{0} must be shorter than {1} characters",
+		"lt.items":   "{0} must contain fewer than {1} items",
+		"lt.number":  "{0} must be less than {1}",
+		"lte.string": "{0} must be at most {1} characters long",
+		"lte.items":  "{0} must contain at most {1} items",
+		"lte.number": "{0} must be {1} or less",
+	},
+	"id": {
+		invalid:      "{0} tidak valid",
+		"required":   "{0} wajib diisi",
+		"email":      "{0} harus berupa alamat email yang valid",
+		"url":        "{0} harus berupa URL yang valid",
+		"uuid":       "{0} harus berupa UUID yang valid",
+		"numeric":    "{0} harus berupa angka",
+		"alphanum":   "{0} hanya boleh berisi huruf dan angka",
+		"oneof":      "{0} harus salah satu dari [{1}]",
+		"min.string": "{0} minimal {1} karakter",
+		"min.items":  "{0} minimal berisi {1} item",
+		"min.number": "{0} minimal {1}",
+		"max.string": "{0} maksimal {1} karakter",
+		"max.items":  "{0} maksimal berisi {1} item",
+		"max.number": "{0} maksimal {1}",
+		"len.string": "{0} harus {1} karakter",
+		"len.items":  "{0} harus berisi {1} item",
+		"len.number": "{0} harus sama dengan {1}",
+		"gt.string":  "{0} harus lebih dari {1} karakter",
+		"gt.items":   "{0} harus berisi lebih dari {1} item",
+		"gt.number":  "{0} harus lebih besar dari {1}",
+		"gte.string": "{0} minimal {1} karakter",
+		"gte.items":  "{0} minimal berisi {1} item",
+		"gte.number": "{0} minimal {1}",
+		"lt.string":  "{0} harus kurang dari {1} karakter",
+		"lt.items":   "{0} harus berisi kurang dari {1} item",
+		"lt.number":  "{0} harus lebih kecil dari {1}",
+		"lte.string": "{0} maksimal {1} karakter",
+		"lte.items":  "{0} maksimal berisi {1} item",
+		"lte.number": "{0} maksimal {1}",
+	},
+}
+
+// translate renders the message of a failed field, falling back to the
+// generic message of the locale for rules without one
+func translate(trans ut.Translator, field validator.FieldError, name string) string {
+	key := field.Tag()
+	if sized[key] {
+		switch field.Kind() {
+		case reflect.String:
+			key += ".string"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			key += ".items"
+		default:
+			key += ".number"
+		}
+	}
+
+	message, err := trans.T(key, name, field.Param())
+	if err != nil {
+		message, _ = trans.T(invalid, name)
+	}
+	return message
+}
+
+// Languages returns the locales of an Accept-Language header by preference,
+// in the form of the locales package: "fr-CH, fr;q=0.9, en;q=0.8" gives
+// fr_CH, fr and en. A regional locale is followed by its language.
+func Languages(acceptLanguage string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+
+	var ranges []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			q = parsed
+		}
+		ranges = append(ranges, weighted{locale: strings.ReplaceAll(tag, "-", "_"), q: q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	locales := make([]string, 0, len(ranges)*2)
+	for _, r := range ranges {
+		locales = append(locales, r.locale)
+		if language, _, regional := strings.Cut(r.locale, "_"); regional {
+			locales = append(locales, language)
+		}
+	}
+	return locales
+}
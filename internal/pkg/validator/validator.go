@@ -1,23 +1,142 @@
 package validator
 
-import "github.com/go-playground/validator"
+import (
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator"
+)
+
+// Rule is a custom validation rule registered by a module, with the message
+// of the fields failing it in each locale. Messages are templates where {0}
+// is the field name and {1} the rule parameter, e.g. "{0} must start with
+// {1}".
+type Rule struct {
+	Tag      string
+	Func     validator.Func
+	Messages map[string]string
+}
 
 // CustomValidator is a custom validator for Echo
 type CustomValidator struct {
-	validator *validator.Validate
+	validator  *validator.Validate
+	translator *ut.UniversalTranslator
 }
 
-// NewCustomValidator
+// NewCustomValidator creates a validator naming fields after their json tag,
+// with messages in English, the fallback, and Indonesian
 func NewCustomValidator() *CustomValidator {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return field.Name
+		}
+		return name
+	})
+
+	translator := ut.New(en.New(), en.New(), id.New())
+	for locale, messages := range messages {
+		trans, _ := translator.GetTranslator(locale)
+		for key, text := range messages {
+			if err := trans.Add(key, text, false); err != nil {
+				panic(err)
+			}
+		}
+	}
+
 	return &CustomValidator{
-		validator: validator.New(),
+		validator:  validate,
+		translator: translator,
 	}
 }
 
-// Validate validates a struct
+// RegisterRule adds a custom rule. Its messages must be in supported
+// locales; fields failing it in another locale get a generic message.
+func (cv *CustomValidator) RegisterRule(rule Rule) error {
+	for locale, text := range rule.Messages {
+		trans, found := cv.translator.GetTranslator(locale)
+		if !found {
+			return fmt.Errorf("rule %s: unsupported locale %q", rule.Tag, locale)
+		}
+		if err := trans.Add(rule.Tag, text, true); err != nil {
+			return fmt.Errorf("rule %s: %w", rule.Tag, err)
+		}
+	}
+	if err := cv.validator.RegisterValidation(rule.Tag, rule.Func); err != nil {
+		return fmt.Errorf("rule %s: %w", rule.Tag, err)
+	}
+	return nil
+}
+
+// Validate validates a struct, reporting the fields that failed in an
+// *Error with messages in the fallback locale
 func (cv *CustomValidator) Validate(i interface{}) error {
 	if err := cv.validator.Struct(i); err != nil {
-		return err
+		failed, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return err
+		}
+		return cv.newError(failed, cv.translator.GetFallback())
 	}
 	return nil
 }
+
+// newError describes the failed fields in the language of trans
+func (cv *CustomValidator) newError(failed validator.ValidationErrors, trans ut.Translator) *Error {
+	fields := make([]errs.FieldError, 0, len(failed))
+	for _, field := range failed {
+		// The namespace starts with the struct name, which clients never see
+		name := field.Namespace()
+		if _, nested, ok := strings.Cut(name, "."); ok {
+			name = nested
+		}
+		fields = append(fields, errs.FieldError{
+			Field:   name,
+			Code:    field.Tag(),
+			Message: translate(trans, field, name),
+		})
+	}
+	return &Error{Fields: fields, failed: failed, validator: cv}
+}
+
+// Error lists the fields of a request that failed validation
+type Error struct {
+	Fields []errs.FieldError
+
+	failed    validator.ValidationErrors
+	validator *CustomValidator
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Message)
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// ErrorKind implements errs.Kinded.
+func (e *Error) ErrorKind() errs.Kind {
+	return errs.Validation
+}
+
+// FieldErrors implements errs.Fielded.
+func (e *Error) FieldErrors() []errs.FieldError {
+	return e.Fields
+}
+
+// Localize implements errs.Localized, describing the fields in the first
+// supported language of an Accept-Language header.
+func (e *Error) Localize(acceptLanguage string) error {
+	trans, _ := e.validator.translator.FindTranslator(Languages(acceptLanguage)...)
+	return e.validator.newError(e.failed, trans)
+}
@@ -0,0 +1,80 @@
+package validator
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type signUp struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Password string   `json:"password,omitempty" validate:"min=8"`
+	Tags     []string `json:"tags" validate:"max=1"`
+	Age      int      `validate:"gte=18"`
+	Handle   string   `json:"handle" validate:"omitempty,slug"`
+	Address  address  `json:"address"`
+}
+
+func TestValidate(t *testing.T) {
+	cv := NewCustomValidator()
+	err := cv.RegisterRule(Rule{
+		Tag: "slug",
+		Func: func(fl validator.FieldLevel) bool {
+			return !strings.ContainsAny(fl.Field().String(), " /")
+		},
+		Messages: map[string]string{"en": "{0} must be a slug"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cv.Validate(&signUp{Email: "nope", Password: "short", Tags: []string{"a", "b"}, Age: 17, Handle: "a b"})
+	var invalid *Error
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+
+	want := []struct{ field, code, message string }{
+		{"email", "email", "email must be a valid email address"},
+		{"password", "min", "password must be at least 8 characters long"},
+		{"tags", "max", "tags must contain at most 1 items"},
+		{"Age", "gte", "Age must be 18 or greater"},
+		{"handle", "slug", "handle must be a slug"},
+		{"address.city", "required", "address.city is required"},
+	}
+	if len(invalid.Fields) != len(want) {
+		t.Fatalf("expected %d fields, got %+v", len(want), invalid.Fields)
+	}
+	for i, field := range invalid.Fields {
+		if field.Field != want[i].field || field.Code != want[i].code || field.Message != want[i].message {
+			t.Errorf("expected %+v, got %+v", want[i], field)
+		}
+	}
+
+	localized := invalid.Localize("fr-CH, id-ID;q=0.9, en;q=0.5").(*Error)
+	if got := localized.Fields[1].Message; got != "password minimal 8 karakter" {
+		t.Errorf("expected an Indonesian message, got %q", got)
+	}
+	if got := localized.Fields[4].Message; got != "handle tidak valid" {
+		t.Errorf("expected the generic message for a rule without a translation, got %q", got)
+	}
+
+	if err := cv.RegisterRule(Rule{Tag: "other", Messages: map[string]string{"xx": "{0}"}}); err == nil {
+		t.Error("expected an error for an unsupported locale")
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	got := Languages("en;q=0.5, pt-BR, *;q=0.1, de;q=0, id;q=0.8")
+	want := []string{"pt_BR", "pt", "id", "en"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -28,6 +28,9 @@ func main() {
 
 	// initialize logger
 	logCfg := logger.DefaultConfig()
+	logCfg.Level = config.Get().Log.Level
+	logCfg.Encoding = config.Get().Log.Encoding
+	logCfg.OutputPath = config.Get().Log.OutputPath
 
 	// Start the application
 	app, err := app.NewApp(&logCfg)
@@ -39,12 +42,20 @@ func main() {
 	// register modules
 	app.RegisterModule(user.NewModule())
 
+	// run a migrate subcommand instead of the server, e.g. `-c config.toml migrate up`
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := app.Migrate(args[1:]); err != nil {
+			log.Fatalf("Error running migrations : %v", err)
+		}
+		return
+	}
+
 	// initialize the application
 	if err := app.Initialize(); err != nil {
 		log.Fatalf("Error initializing application : %v", err)
 		os.Exit(1)
 	}
 
-	// Start the application
-	app.Start()
+	// Start the application and exit once it has shut down
+	os.Exit(app.Start())
 }
@@ -0,0 +1,44 @@
+package user
+
+import (
+	"go-modular-boilerplate/internal/pkg/password"
+	"time"
+)
+
+// Config is the [modules.user] section of the configuration file
+type Config struct {
+	Password password.Config `mapstructure:"password"`
+	Auth     AuthConfig      `mapstructure:"auth"`
+	Deletion DeletionConfig  `mapstructure:"deletion"`
+}
+
+// AuthConfig is the [modules.user.auth] section
+type AuthConfig struct {
+	// RefreshTTL is how long a refresh token can be used; each refresh
+	// issues a new one
+	RefreshTTL time.Duration `mapstructure:"refresh_ttl" validate:"gt=0"`
+}
+
+// DeletionConfig is the [modules.user.deletion] section
+type DeletionConfig struct {
+	// Retention is how long deleted users can be restored before they are
+	// purged, 0 keeps them until they are purged by hand
+	Retention time.Duration `mapstructure:"retention" validate:"gte=0"`
+	// PurgeInterval is how often users deleted for longer than Retention
+	// are purged
+	PurgeInterval time.Duration `mapstructure:"purge_interval" validate:"gt=0"`
+}
+
+// DefaultConfig returns the configuration used for keys missing from the file
+func DefaultConfig() Config {
+	return Config{
+		Password: password.DefaultConfig(),
+		Auth: AuthConfig{
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		Deletion: DeletionConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+	}
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+)
+
+// RefreshToken is an issued refresh token. Only the hash of the token is
+// stored; tokens rotated from the same login share a FamilyID.
+type RefreshToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `json:"user_id"`
+	FamilyID   string     `json:"family_id"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy *uint      `json:"replaced_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for RefreshToken
+func (*RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// Rotated reports whether the token has been exchanged for another one, as
+// opposed to revoked by a logout
+func (t *RefreshToken) Rotated() bool {
+	return t.ReplacedBy != nil
+}
+
+// Expired reports whether the token has expired at now
+func (t *RefreshToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
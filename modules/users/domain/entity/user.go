@@ -1,17 +1,26 @@
 package entity
 
 import (
+	"go-modular-boilerplate/internal/pkg/database"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-// User represents a user entity
+// User represents a user entity. Its version changes with every update, see
+// database.Versioned. Deleted users are kept, with DeletedAt set, until they
+// are purged. Emails are unique regardless of case, the index is created by
+// the case_insensitive_user_emails migration.
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `json:"name"`
+	Email     string         `gorm:"size:255" json:"email"`
+	Password  string         `json:"-"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	database.Versioned
 }
 
 // TableName specifies the table name for User
@@ -19,14 +28,20 @@ func (*User) TableName() string {
 	return "users"
 }
 
-// NewUser creates a new user
-func NewUser(name, email, password string) *User {
+// NewUser creates a new user. The password hash is set by the user
+// service; the plain text password is never stored.
+func NewUser(name, email string) *User {
 	now := time.Now()
 	return &User{
 		Name:      name,
-		Email:     email,
-		Password:  password,
+		Email:     NormalizeEmail(email),
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 }
+
+// NormalizeEmail returns the form emails are stored and looked up in. Emails
+// are compared case-insensitively, so they are stored in lower case.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
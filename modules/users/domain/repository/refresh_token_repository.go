@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"go-modular-boilerplate/modules/users/domain/entity"
+	"time"
+)
+
+// RefreshTokenRepository defines the refresh token repository interface
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *entity.RefreshToken) error
+	FindByHash(ctx context.Context, hash string) (*entity.RefreshToken, error)
+	// Rotate marks the token as used and replaced by another one. It returns
+	// false if the token had already been used or revoked.
+	Rotate(ctx context.Context, id uint, replacedBy uint, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	// RevokeUser revokes every refresh token of a user
+	RevokeUser(ctx context.Context, userID uint, at time.Time) error
+}
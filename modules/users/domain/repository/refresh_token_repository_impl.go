@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/modules/users/domain/entity"
+	"time"
+)
+
+type RefreshTokenRepositoryImpl struct{}
+
+// Create implements RefreshTokenRepository.
+func (r RefreshTokenRepositoryImpl) Create(ctx context.Context, token *entity.RefreshToken) error {
+	return database.TranslateError(database.Conn(ctx).Create(token).Error)
+}
+
+// FindByHash implements RefreshTokenRepository. Tokens are read from the
+// primary because a replica may not have a freshly rotated token yet.
+func (r RefreshTokenRepositoryImpl) FindByHash(ctx context.Context, hash string) (*entity.RefreshToken, error) {
+	var token entity.RefreshToken
+	result := database.Conn(database.WithPrimary(ctx)).Where("token_hash = ?", hash).First(&token)
+	if result.Error != nil {
+		return nil, database.TranslateError(result.Error)
+	}
+	return &token, nil
+}
+
+// Rotate implements RefreshTokenRepository. The token is only updated if it
+// has not been revoked yet, so of two concurrent refreshes only one wins.
+func (r RefreshTokenRepositoryImpl) Rotate(ctx context.Context, id uint, replacedBy uint, at time.Time) (bool, error) {
+	result := database.Conn(ctx).Model(&entity.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": at, "replaced_by": replacedBy})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeFamily implements RefreshTokenRepository.
+func (r RefreshTokenRepositoryImpl) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	return database.Conn(ctx).Model(&entity.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", at).Error
+}
+
+// RevokeUser implements RefreshTokenRepository.
+func (r RefreshTokenRepositoryImpl) RevokeUser(ctx context.Context, userID uint, at time.Time) error {
+	return database.Conn(ctx).Model(&entity.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+}
+
+func NewRefreshTokenRepositoryImpl() RefreshTokenRepository {
+	return RefreshTokenRepositoryImpl{}
+}
@@ -2,15 +2,42 @@ package repository
 
 import (
 	"context"
+	"go-modular-boilerplate/internal/pkg/query"
 	"go-modular-boilerplate/modules/users/domain/entity"
+	"time"
 )
 
-// UserRepository defines the user repository interface
+// UserQuery lists the fields users can be filtered and sorted on
+var UserQuery = &query.Schema{
+	Fields: map[string]query.Field{
+		"id":         {Kind: query.Int, Operators: []string{query.Eq}, Sortable: true},
+		"name":       {Kind: query.String, Operators: []string{query.Eq, query.Contains}, Sortable: true},
+		"email":      {Kind: query.String, Operators: []string{query.Eq}, Sortable: true, Normalize: entity.NormalizeEmail},
+		"created_at": {Kind: query.Time, Operators: []string{query.Gt, query.Gte, query.Lt, query.Lte}, Sortable: true},
+		"updated_at": {Kind: query.Time, Sortable: true},
+	},
+}
+
+// UserRepository defines the user repository interface. Deleted users are
+// left out unless a method says otherwise.
 type UserRepository interface {
-	FindAll(ctx context.Context) ([]*entity.User, error)
+	FindAll(ctx context.Context, spec *query.Spec, includeDeleted bool) (*query.Page[*entity.User], error)
 	FindByID(ctx context.Context, id uint) (*entity.User, error)
+	// FindByIDWithDeleted also finds a deleted user
+	FindByIDWithDeleted(ctx context.Context, id uint) (*entity.User, error)
 	FindByEmail(ctx context.Context, email string) (*entity.User, error)
 	Create(ctx context.Context, user *entity.User) error
+	// Update, UpdateColumns, Delete and Restore return
+	// database.ErrStaleVersion if the user changed since it was read
 	Update(ctx context.Context, user *entity.User) error
-	Delete(ctx context.Context, id uint) error
+	UpdateColumns(ctx context.Context, user *entity.User, columns map[string]interface{}) error
+	UpdatePassword(ctx context.Context, id uint, hash string) error
+	// Delete soft deletes a user, Restore undoes it
+	Delete(ctx context.Context, user *entity.User) error
+	Restore(ctx context.Context, user *entity.User) error
+	// Purge deletes a user for good, deleted or not
+	Purge(ctx context.Context, user *entity.User) error
+	// PurgeDeleted deletes for good at most limit users deleted before the
+	// given time and returns those it deleted
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) ([]*entity.User, error)
 }
@@ -4,45 +4,125 @@ import (
 	"context"
 	"errors"
 	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"go-modular-boilerplate/internal/pkg/query"
 	"go-modular-boilerplate/modules/users/domain/entity"
+	"time"
+
+	"gorm.io/gorm"
 )
 
+// Errors returned by the repositories besides those of
+// database.TranslateError
 var (
-	ERR_RECORD_NOT_FOUND = errors.New("record not found")
+	ERR_RECORD_NOT_FOUND = database.ErrNotFound
+	ERR_DUPLICATE_EMAIL  = errs.New(errs.Conflict, "duplicate_email", "email already exists")
 )
 
 type UserRepositoryImpl struct{}
 
 // Create implements UserRepository.
 func (r UserRepositoryImpl) Create(ctx context.Context, user *entity.User) error {
-	return database.DB.WithContext(ctx).Create(user).Error
+	return translate(database.Conn(ctx).Create(user).Error)
 }
 
 // Delete implements UserRepository.
-func (r UserRepositoryImpl) Delete(ctx context.Context, id uint) error {
-	return database.DB.WithContext(ctx).Delete(&entity.User{}, id).Error
+func (r UserRepositoryImpl) Delete(ctx context.Context, user *entity.User) error {
+	return database.DeleteVersioned(ctx, user)
 }
 
-// FindAll finds all users
-func (r UserRepositoryImpl) FindAll(ctx context.Context) ([]*entity.User, error) {
-	var users []*entity.User
-	result := database.DB.WithContext(ctx).Find(&users)
+// Restore implements UserRepository.
+func (r UserRepositoryImpl) Restore(ctx context.Context, user *entity.User) error {
+	version := user.CurrentVersion()
+	result := database.Conn(ctx).Unscoped().Model(user).
+		Where("version = ? AND deleted_at IS NOT NULL", version).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
 	if result.Error != nil {
-		return nil, result.Error
+		return database.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return database.ErrStaleVersion
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	user.Version = version + 1
+	return nil
+}
+
+// Purge implements UserRepository.
+func (r UserRepositoryImpl) Purge(ctx context.Context, user *entity.User) error {
+	return database.TranslateError(database.Conn(ctx).Unscoped().Delete(&entity.User{}, user.ID).Error)
+}
+
+// PurgeDeleted implements UserRepository. A user restored between the select
+// and the delete is kept and left out of the result.
+func (r UserRepositoryImpl) PurgeDeleted(ctx context.Context, before time.Time, limit int) ([]*entity.User, error) {
+	var purged []*entity.User
+	err := database.Transaction(ctx, func(ctx context.Context) error {
+		var users []*entity.User
+		err := database.Conn(ctx).Unscoped().
+			Where("deleted_at < ?", before).
+			Order("id").Limit(limit).
+			Find(&users).Error
+		if err != nil || len(users) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(users))
+		for i, user := range users {
+			ids[i] = user.ID
+		}
+		result := database.Conn(ctx).Unscoped().
+			Where("id IN ? AND deleted_at < ?", ids, before).
+			Delete(&entity.User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == int64(len(users)) {
+			purged = users
+			return nil
+		}
+
+		// Some users were restored meanwhile, they are still there
+		var kept []uint
+		err = database.Conn(ctx).Unscoped().Model(&entity.User{}).
+			Where("id IN ?", ids).
+			Pluck("id", &kept).Error
+		if err != nil {
+			return err
+		}
+		restored := make(map[uint]bool, len(kept))
+		for _, id := range kept {
+			restored[id] = true
+		}
+		for _, user := range users {
+			if !restored[user.ID] {
+				purged = append(purged, user)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return purged, nil
+}
+
+// FindAll finds a page of users matching the spec, see UserQuery
+func (r UserRepositoryImpl) FindAll(ctx context.Context, spec *query.Spec, includeDeleted bool) (*query.Page[*entity.User], error) {
+	db := database.Conn(ctx)
+	if includeDeleted {
+		db = db.Unscoped()
 	}
-	return users, nil
+	page, err := query.Find[*entity.User](db, UserQuery, spec)
+	return page, database.TranslateError(err)
 }
 
 // FindByEmail implements UserRepository.
 func (r UserRepositoryImpl) FindByEmail(ctx context.Context, email string) (*entity.User, error) {
 	var user entity.User
-	result := database.DB.WithContext(ctx).Where("email = ?", email).First(&user)
+	result := database.Conn(ctx).Where("email = ?", entity.NormalizeEmail(email)).First(&user)
 	if result.Error != nil {
-		if result.RowsAffected == 0 {
-			return nil, ERR_RECORD_NOT_FOUND
-		}
-
-		return nil, result.Error
+		return nil, database.TranslateError(result.Error)
 	}
 	return &user, nil
 }
@@ -50,16 +130,51 @@ func (r UserRepositoryImpl) FindByEmail(ctx context.Context, email string) (*ent
 // FindByID implements UserRepository.
 func (r UserRepositoryImpl) FindByID(ctx context.Context, id uint) (*entity.User, error) {
 	var user entity.User
-	result := database.DB.WithContext(ctx).First(&user, id)
+	result := database.Conn(ctx).First(&user, id)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, database.TranslateError(result.Error)
+	}
+	return &user, nil
+}
+
+// FindByIDWithDeleted implements UserRepository.
+func (r UserRepositoryImpl) FindByIDWithDeleted(ctx context.Context, id uint) (*entity.User, error) {
+	var user entity.User
+	result := database.Conn(ctx).Unscoped().First(&user, id)
+	if result.Error != nil {
+		return nil, database.TranslateError(result.Error)
 	}
 	return &user, nil
 }
 
 // Update implements UserRepository.
 func (r UserRepositoryImpl) Update(ctx context.Context, user *entity.User) error {
-	return database.DB.WithContext(ctx).Save(user).Error
+	return r.UpdateColumns(ctx, user, map[string]interface{}{
+		"name":     user.Name,
+		"email":    user.Email,
+		"password": user.Password,
+	})
+}
+
+// UpdateColumns sets the given columns of a user, and its updated_at,
+// leaving the others untouched
+func (r UserRepositoryImpl) UpdateColumns(ctx context.Context, user *entity.User, columns map[string]interface{}) error {
+	return translate(database.UpdateVersioned(ctx, user, columns))
+}
+
+// UpdatePassword implements UserRepository.
+func (r UserRepositoryImpl) UpdatePassword(ctx context.Context, id uint, hash string) error {
+	return database.TranslateError(database.Conn(ctx).Model(&entity.User{}).Where("id = ?", id).Update("password", hash).Error)
+}
+
+// translate maps the unique violation of the email index, the only unique
+// column besides the key, to ERR_DUPLICATE_EMAIL
+func translate(err error) error {
+	err = database.TranslateError(err)
+	if errors.Is(err, database.ErrDuplicatedKey) {
+		return ERR_DUPLICATE_EMAIL.Wrap(err)
+	}
+	return err
 }
 
 func NewUserRepositoryImpl() UserRepository {
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"go-modular-boilerplate/internal/pkg/auth"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"go-modular-boilerplate/internal/pkg/jwt"
+	"go-modular-boilerplate/modules/users/domain/entity"
+	"go-modular-boilerplate/modules/users/domain/repository"
+	"strconv"
+	"time"
+)
+
+// Errors
+var (
+	ErrInvalidCredentials  = errs.New(errs.Unauthorized, "invalid_credentials", "invalid email or password")
+	ErrInvalidRefreshToken = errs.New(errs.Unauthorized, "invalid_refresh_token", "invalid refresh token")
+	ErrRefreshTokenReused  = errs.New(errs.Unauthorized, "refresh_token_reused", "refresh token has already been used")
+	ErrInvalidAccessToken  = errs.New(errs.Validation, "invalid_access_token", "invalid access token")
+	ErrRevocationDisabled  = errors.New("token revocation is not enabled")
+)
+
+// Tokens is an access token with the refresh token that renews it
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// AuthService issues access tokens and rotating refresh tokens
+type AuthService struct {
+	users       *UserService
+	tokens      repository.RefreshTokenRepository
+	jwt         jwt.JWT
+	revocations auth.Revocations
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+}
+
+// NewAuthService creates a new auth service. revocations may be nil, access
+// tokens then cannot be revoked before they expire.
+func NewAuthService(users *UserService, tokens repository.RefreshTokenRepository, j jwt.JWT, revocations auth.Revocations, accessTTL, refreshTTL time.Duration) *AuthService {
+	return &AuthService{
+		users:       users,
+		tokens:      tokens,
+		jwt:         j,
+		revocations: revocations,
+		accessTTL:   accessTTL,
+		refreshTTL:  refreshTTL,
+	}
+}
+
+// Login checks the user's credentials and starts a new session
+func (s *AuthService) Login(ctx context.Context, email, plain string) (*Tokens, error) {
+	user, err := s.users.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, errs.NotFound) {
+			// Spend the same time as a wrong password so response times
+			// do not reveal which emails are registered
+			s.users.hasher.Hash(plain)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if err := s.users.VerifyPassword(ctx, user, plain); err != nil {
+		if errors.Is(err, ErrInvalidPassword) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	familyID, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens *Tokens
+	err = database.Transaction(ctx, func(ctx context.Context) error {
+		tokens, _, err = s.issue(ctx, user, familyID)
+		return err
+	})
+	return tokens, err
+}
+
+// Refresh exchanges a refresh token for new tokens. Each refresh token can
+// be used once; presenting one again means it has leaked, so every token of
+// its session is revoked and ErrRefreshTokenReused is returned. A token
+// revoked by a logout is only invalid.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	var tokens *Tokens
+	var reusedFamily string
+
+	err := database.Transaction(ctx, func(ctx context.Context) error {
+		stored, err := s.tokens.FindByHash(ctx, hashToken(refreshToken))
+		if err != nil {
+			if errors.Is(err, errs.NotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if stored.RevokedAt != nil {
+			if !stored.Rotated() {
+				return ErrInvalidRefreshToken
+			}
+			reusedFamily = stored.FamilyID
+			return ErrRefreshTokenReused
+		}
+		if stored.Expired(time.Now()) {
+			return ErrInvalidRefreshToken
+		}
+
+		user, err := s.users.userRepo.FindByID(ctx, stored.UserID)
+		if err != nil {
+			if errors.Is(err, errs.NotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		var replacement *entity.RefreshToken
+		tokens, replacement, err = s.issue(ctx, user, stored.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		rotated, err := s.tokens.Rotate(ctx, stored.ID, replacement.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		if !rotated {
+			// A concurrent request used the same token first
+			reusedFamily = stored.FamilyID
+			return ErrRefreshTokenReused
+		}
+		return nil
+	})
+
+	if errors.Is(err, ErrRefreshTokenReused) {
+		// Outside the transaction, which has been rolled back
+		if revokeErr := s.tokens.RevokeFamily(ctx, reusedFamily, time.Now()); revokeErr != nil {
+			return nil, errors.Join(err, revokeErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Logout revokes the session the refresh token belongs to. Unknown tokens
+// are ignored so logging out twice succeeds.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	stored, err := s.tokens.FindByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, errs.NotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.tokens.RevokeFamily(ctx, stored.FamilyID, time.Now())
+}
+
+// RevokeToken revokes an access token until it expires. The token must be
+// valid; revoking it is allowed to anyone holding it.
+func (s *AuthService) RevokeToken(ctx context.Context, token string) error {
+	claims, err := s.jwt.ParseToken(token)
+	if err != nil {
+		return ErrInvalidAccessToken
+	}
+	return s.RevokeClaims(ctx, claims)
+}
+
+// RevokeClaims revokes the access token with the given claims
+func (s *AuthService) RevokeClaims(ctx context.Context, claims *jwt.Claims) error {
+	if s.revocations == nil {
+		return ErrRevocationDisabled
+	}
+	if claims.ID == "" {
+		// Tokens without a jti can only be revoked with their subject
+		return ErrInvalidAccessToken
+	}
+	return s.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt)
+}
+
+// RevokeSessions ends every session of a user: the refresh tokens are
+// revoked and access tokens issued until now are rejected
+func (s *AuthService) RevokeSessions(ctx context.Context, userID uint) error {
+	if s.revocations == nil {
+		return ErrRevocationDisabled
+	}
+
+	now := time.Now()
+	if err := s.tokens.RevokeUser(ctx, userID, now); err != nil {
+		return err
+	}
+	return s.revocations.RevokeSubject(ctx, strconv.FormatUint(uint64(userID), 10), now)
+}
+
+// RevokeUserSessions is RevokeSessions for a user that must exist, it
+// returns ErrUserNotFound otherwise
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID uint) error {
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+	return s.RevokeSessions(ctx, userID)
+}
+
+// issue creates an access token and a refresh token in the given family
+func (s *AuthService) issue(ctx context.Context, user *entity.User, familyID string) (*Tokens, *entity.RefreshToken, error) {
+	accessToken, err := s.jwt.GenerateToken(jwt.Claims{
+		Subject: strconv.FormatUint(uint64(user.ID), 10),
+		Extra:   map[string]interface{}{"email": user.Email},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, err := randomString(32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored := &entity.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+		CreatedAt: time.Now(),
+	}
+	if err := s.tokens.Create(ctx, stored); err != nil {
+		return nil, nil, err
+	}
+
+	return &Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.accessTTL,
+	}, stored, nil
+}
+
+// randomString returns n random bytes encoded for use in URLs
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the form of a refresh token stored in the database
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,55 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"go-modular-boilerplate/modules/users/domain/repository"
+	"go-modular-boilerplate/modules/users/domain/service"
+	"go-modular-boilerplate/modules/users/internal/userstest"
+	"testing"
+)
+
+// newTestAuthService opens an in-memory database as database.DB and returns
+// an auth service with a userstest user
+func newTestAuthService(t *testing.T) *service.AuthService {
+	t.Helper()
+
+	userstest.OpenDB(t)
+	users, _ := userstest.NewUserService(t, repository.NewUserRepositoryImpl())
+	return userstest.NewAuth(users).Service
+}
+
+func TestRefreshDistinguishesLogoutFromReuse(t *testing.T) {
+	s := newTestAuthService(t)
+	ctx := context.Background()
+
+	login := func() *service.Tokens {
+		t.Helper()
+		tokens, err := s.Login(ctx, userstest.Email, userstest.Password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tokens
+	}
+
+	loggedOut := login()
+	if err := s.Logout(ctx, loggedOut.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.Refresh(ctx, loggedOut.RefreshToken)
+	if !errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+		t.Errorf("expected a logged out token to be invalid, got %v", err)
+	}
+
+	rotated := login()
+	renewed, err := s.Refresh(ctx, rotated.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, service.ErrRefreshTokenReused) {
+		t.Errorf("expected a rotated token to be reported as reused, got %v", err)
+	}
+	if _, err := s.Refresh(ctx, renewed.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("expected the session to be revoked after a reuse, got %v", err)
+	}
+}
@@ -3,80 +3,291 @@ package service
 import (
 	"context"
 	"errors"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"go-modular-boilerplate/internal/pkg/password"
+	"go-modular-boilerplate/internal/pkg/query"
 	"go-modular-boilerplate/modules/users/domain/entity"
 	"go-modular-boilerplate/modules/users/domain/repository"
+	"time"
 )
 
 // Errors
 var (
-	ErrUserNotFound     = errors.New("user not found")
-	ErrEmailAlreadyUsed = errors.New("email already in use")
+	ErrUserNotFound     = errs.New(errs.NotFound, "user_not_found", "user not found")
+	ErrEmailAlreadyUsed = errs.New(errs.Conflict, "email_already_used", "email already in use")
+	ErrInvalidPassword  = errs.New(errs.Unauthorized, "invalid_password", "invalid password")
+	ErrUserNotDeleted   = errs.New(errs.Conflict, "user_not_deleted", "user is not deleted")
 )
 
+// purgeBatch is the number of users PurgeDeletedUsers deletes per query
+const purgeBatch = 100
+
 // UserService handles user domain logic
 type UserService struct {
 	userRepo repository.UserRepository
+	hasher   password.Hasher
 }
 
 // NewUserService creates a new user service
-func NewUserService(userRepo repository.UserRepository) *UserService {
+func NewUserService(userRepo repository.UserRepository, hasher password.Hasher) *UserService {
 	return &UserService{
 		userRepo: userRepo,
+		hasher:   hasher,
 	}
 }
 
-// GetAllUsers gets all users
-func (s *UserService) GetAllUsers(ctx context.Context) ([]*entity.User, error) {
-	return s.userRepo.FindAll(ctx)
+// GetAllUsers gets a page of users, deleted users only if includeDeleted is
+// set
+func (s *UserService) GetAllUsers(ctx context.Context, spec *query.Spec, includeDeleted bool) (*query.Page[*entity.User], error) {
+	return s.userRepo.FindAll(ctx, spec, includeDeleted)
 }
 
 // GetUserByID gets a user by ID
 func (s *UserService) GetUserByID(ctx context.Context, id uint) (*entity.User, error) {
+	return s.findUser(ctx, id)
+}
+
+// GetUserForUpdate gets a user by ID from the primary, so it is read at its
+// latest version before being updated or deleted
+func (s *UserService) GetUserForUpdate(ctx context.Context, id uint) (*entity.User, error) {
+	return s.findUser(database.WithPrimary(ctx), id)
+}
+
+// findUser returns ErrUserNotFound for a missing user
+func (s *UserService) findUser(ctx context.Context, id uint) (*entity.User, error) {
 	user, err := s.userRepo.FindByID(ctx, id)
 	if err != nil {
+		if errors.Is(err, errs.NotFound) {
+			return nil, ErrUserNotFound.Wrap(err)
+		}
 		return nil, err
 	}
-	if user == nil {
-		return nil, ErrUserNotFound
-	}
 	return user, nil
 }
 
-// CreateUser creates a new user
-func (s *UserService) CreateUser(ctx context.Context, user *entity.User) error {
-	// existingUser, err := s.userRepo.FindByEmail(ctx, user.Email)
-	// if err != nil && err != repository.ERR_RECORD_NOT_FOUND {
-	// 	return err
-	// }
-	// if existingUser != nil {
-	// 	return ErrEmailAlreadyUsed
-	// }
+// CreateUser creates a new user with the given password
+func (s *UserService) CreateUser(ctx context.Context, user *entity.User, plain string) error {
+	user.Email = entity.NormalizeEmail(user.Email)
+	if err := s.checkEmail(ctx, user); err != nil {
+		return err
+	}
 
-	return s.userRepo.Create(ctx, user)
+	if err := s.SetPassword(user, plain); err != nil {
+		return err
+	}
+
+	// The unique index catches a concurrent request taking the email
+	// after the check
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ERR_DUPLICATE_EMAIL) {
+			return ErrEmailAlreadyUsed.Wrap(err)
+		}
+		return err
+	}
+	return nil
 }
 
-// UpdateUser updates a user
-func (s *UserService) UpdateUser(ctx context.Context, user *entity.User) error {
-	existingUser, err := s.userRepo.FindByID(ctx, user.ID)
+// checkEmail returns ErrEmailAlreadyUsed if another user has the user's
+// email
+func (s *UserService) checkEmail(ctx context.Context, user *entity.User) error {
+	existingUser, err := s.userRepo.FindByEmail(database.WithPrimary(ctx), user.Email)
 	if err != nil {
+		if errors.Is(err, errs.NotFound) {
+			return nil
+		}
 		return err
 	}
-	if existingUser == nil {
-		return ErrUserNotFound
+	if existingUser.ID != user.ID {
+		return ErrEmailAlreadyUsed
 	}
-
-	return s.userRepo.Update(ctx, user)
+	return nil
 }
 
-// DeleteUser deletes a user
-func (s *UserService) DeleteUser(ctx context.Context, id uint) error {
-	existingUser, err := s.userRepo.FindByID(ctx, id)
+// SetPassword replaces the user's password hash, the user still has to be
+// saved
+func (s *UserService) SetPassword(user *entity.User, plain string) error {
+	hash, err := s.hasher.Hash(plain)
 	if err != nil {
 		return err
 	}
-	if existingUser == nil {
-		return ErrUserNotFound
+	user.Password = hash
+	return nil
+}
+
+// VerifyPassword returns ErrInvalidPassword unless plain matches the user's
+// password. A hash made with an outdated algorithm or parameters is
+// replaced with a new one once the password is known to be correct.
+func (s *UserService) VerifyPassword(ctx context.Context, user *entity.User, plain string) error {
+	if err := s.hasher.Verify(user.Password, plain); err != nil {
+		if errors.Is(err, password.ErrMismatch) || errors.Is(err, password.ErrUnknownFormat) {
+			return ErrInvalidPassword
+		}
+		return err
 	}
 
-	return s.userRepo.Delete(ctx, id)
+	if s.hasher.NeedsRehash(user.Password) {
+		// Failing to upgrade the hash must not fail the login, the next
+		// one tries again
+		if hash, err := s.hasher.Hash(plain); err == nil {
+			if err := s.userRepo.UpdatePassword(ctx, user.ID, hash); err == nil {
+				user.Password = hash
+			}
+		}
+	}
+
+	return nil
+}
+
+// UpdateUser updates a user read with GetUserForUpdate. It returns
+// database.ErrStaleVersion if the user changed since it was read.
+func (s *UserService) UpdateUser(ctx context.Context, user *entity.User) error {
+	user.Email = entity.NormalizeEmail(user.Email)
+	if err := s.checkEmail(ctx, user); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		if errors.Is(err, repository.ERR_DUPLICATE_EMAIL) {
+			return ErrEmailAlreadyUsed.Wrap(err)
+		}
+		return err
+	}
+	return nil
+}
+
+// UserChanges are the fields of a partial update, nil fields are left
+// unchanged
+type UserChanges struct {
+	Name     *string
+	Email    *string
+	Password *string
+}
+
+// UserUpdated is the payload of the user.updated event
+type UserUpdated struct {
+	User *entity.User
+	// Fields are the names of the fields whose value changed
+	Fields []string
+}
+
+// UserDeleted is the payload of the user.deleted event
+type UserDeleted struct {
+	User *entity.User
+	// Purged is set when the user is deleted for good, it can no longer be
+	// restored
+	Purged bool
+}
+
+// PatchUser applies changes to a user read with GetUserForUpdate, writing
+// only the columns whose value changes. It returns the updated user and the
+// names of the changed fields, none when the changes match the user, or
+// database.ErrStaleVersion if the user changed since it was read.
+func (s *UserService) PatchUser(ctx context.Context, user *entity.User, changes UserChanges) (*entity.User, []string, error) {
+	columns := make(map[string]interface{})
+	var fields []string
+
+	if changes.Name != nil && *changes.Name != user.Name {
+		user.Name = *changes.Name
+		columns["name"] = user.Name
+		fields = append(fields, "name")
+	}
+	if changes.Email != nil {
+		if email := entity.NormalizeEmail(*changes.Email); email != user.Email {
+			user.Email = email
+			if err := s.checkEmail(ctx, user); err != nil {
+				return nil, nil, err
+			}
+			columns["email"] = user.Email
+			fields = append(fields, "email")
+		}
+	}
+	if changes.Password != nil {
+		if err := s.SetPassword(user, *changes.Password); err != nil {
+			return nil, nil, err
+		}
+		columns["password"] = user.Password
+		fields = append(fields, "password")
+	}
+
+	if len(fields) == 0 {
+		return user, nil, nil
+	}
+	if err := s.userRepo.UpdateColumns(ctx, user, columns); err != nil {
+		if errors.Is(err, repository.ERR_DUPLICATE_EMAIL) {
+			return nil, nil, ErrEmailAlreadyUsed.Wrap(err)
+		}
+		return nil, nil, err
+	}
+	return user, fields, nil
+}
+
+// DeleteUser soft deletes a user read with GetUserForUpdate, it can be
+// restored until it is purged. It returns database.ErrStaleVersion if the
+// user changed since it was read.
+func (s *UserService) DeleteUser(ctx context.Context, user *entity.User) error {
+	return s.userRepo.Delete(ctx, user)
+}
+
+// RestoreUser restores a deleted user. It returns ErrUserNotDeleted for a
+// user that is not deleted.
+func (s *UserService) RestoreUser(ctx context.Context, id uint) (*entity.User, error) {
+	user, err := s.findUserWithDeleted(database.WithPrimary(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if !user.DeletedAt.Valid {
+		return nil, ErrUserNotDeleted
+	}
+
+	if err := s.userRepo.Restore(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// PurgeUser deletes a user for good, whether it is deleted or not, and
+// returns it
+func (s *UserService) PurgeUser(ctx context.Context, id uint) (*entity.User, error) {
+	user, err := s.findUserWithDeleted(database.WithPrimary(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.Purge(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// PurgeDeletedUsers deletes for good the users deleted before the given time
+// and returns them. It stops between batches once ctx is done. The users
+// purged before a failure are returned with the error.
+func (s *UserService) PurgeDeletedUsers(ctx context.Context, before time.Time) ([]*entity.User, error) {
+	var purged []*entity.User
+	for {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		users, err := s.userRepo.PurgeDeleted(ctx, before, purgeBatch)
+		if err != nil {
+			return purged, err
+		}
+		if len(users) == 0 {
+			return purged, nil
+		}
+		purged = append(purged, users...)
+	}
+}
+
+// findUserWithDeleted is findUser including deleted users
+func (s *UserService) findUserWithDeleted(ctx context.Context, id uint) (*entity.User, error) {
+	user, err := s.userRepo.FindByIDWithDeleted(ctx, id)
+	if err != nil {
+		if errors.Is(err, errs.NotFound) {
+			return nil, ErrUserNotFound.Wrap(err)
+		}
+		return nil, err
+	}
+	return user, nil
 }
@@ -0,0 +1,19 @@
+package request
+
+// LoginRequest represents a request to log in
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// RefreshTokenRequest represents a request carrying a refresh token, used
+// to refresh and to log out
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// RevokeTokenRequest represents a request to revoke an access token, the
+// token of the request itself when Token is empty
+type RevokeTokenRequest struct {
+	Token string `json:"token"`
+}
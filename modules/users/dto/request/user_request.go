@@ -13,3 +13,11 @@ type UpdateUserRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"omitempty,min=6"`
 }
+
+// PatchUserRequest represents a JSON Merge Patch of a user. Members missing
+// from the patch are left unchanged and are not validated.
+type PatchUserRequest struct {
+	Name     *string `json:"name" validate:"omitempty,min=1"`
+	Email    *string `json:"email" validate:"omitempty,email"`
+	Password *string `json:"password" validate:"omitempty,min=6"`
+}
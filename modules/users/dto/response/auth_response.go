@@ -0,0 +1,23 @@
+package response
+
+import (
+	"go-modular-boilerplate/modules/users/domain/service"
+)
+
+// TokenResponse represents issued tokens
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// FromTokens converts issued tokens to a token response
+func FromTokens(tokens *service.Tokens) *TokenResponse {
+	return &TokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+		RefreshToken: tokens.RefreshToken,
+	}
+}
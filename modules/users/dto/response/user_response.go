@@ -14,17 +14,24 @@ type UserResponse struct {
 	Email     string    `json:"email"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is only set for deleted users, which are only listed on
+	// request
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // FromEntity converts a user entity to a user response
 func FromEntity(user *entity.User) *UserResponse {
-	return &UserResponse{
+	res := &UserResponse{
 		ID:        user.ID,
 		Name:      user.Name,
 		Email:     user.Email,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	}
+	if user.DeletedAt.Valid {
+		res.DeletedAt = &user.DeletedAt.Time
+	}
+	return res
 }
 
 // FromEntities converts a slice of user entities to a slice of user responses
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/auth"
+	"go-modular-boilerplate/internal/pkg/bus"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"go-modular-boilerplate/internal/pkg/rbac"
+	"go-modular-boilerplate/modules/users/domain/service"
+	"go-modular-boilerplate/modules/users/dto/request"
+	"go-modular-boilerplate/modules/users/dto/response"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+// AuthHandler handles login, token refresh and logout
+type AuthHandler struct {
+	authService *service.AuthService
+	log         *logger.Logger
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(log *logger.Logger, authService *service.AuthService) *AuthHandler {
+	return &AuthHandler{
+		authService: authService,
+		log:         log,
+	}
+}
+
+// Login exchanges an email and password for tokens
+func (h *AuthHandler) Login(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	req := new(request.LoginRequest)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	tokens, err := h.authService.Login(ctx, req.Email, req.Password)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.FromTokens(tokens))
+}
+
+// Refresh exchanges a refresh token for new tokens
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	req := new(request.RefreshTokenRequest)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	tokens, err := h.authService.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrRefreshTokenReused) {
+			// Clients are not told the token was reused
+			h.log.Warn("Refresh token reused, session revoked")
+			return service.ErrInvalidRefreshToken
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.FromTokens(tokens))
+}
+
+// Logout revokes the session of a refresh token
+func (h *AuthHandler) Logout(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	req := new(request.RefreshTokenRequest)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	if err := h.authService.Logout(ctx, req.RefreshToken); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeToken revokes the access token in the body, or the one the request
+// is authenticated with
+func (h *AuthHandler) RevokeToken(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	// The body is optional
+	req := new(request.RevokeTokenRequest)
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(req); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if req.Token != "" {
+		err = h.authService.RevokeToken(ctx, req.Token)
+	} else {
+		claims, _ := auth.FromEcho(c)
+		err = h.authService.RevokeClaims(ctx, claims)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeOwnSessions logs the authenticated user out everywhere
+func (h *AuthHandler) RevokeOwnSessions(c echo.Context) error {
+	claims, _ := auth.FromEcho(c)
+	id, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil {
+		return ErrInvalidUserID
+	}
+
+	if err := h.authService.RevokeSessions(c.Request().Context(), uint(id)); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeUserSessions logs a user out everywhere
+func (h *AuthHandler) RevokeUserSessions(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return ErrInvalidUserID
+	}
+
+	if err := h.authService.RevokeUserSessions(c.Request().Context(), uint(id)); err != nil {
+		return err
+	}
+
+	h.log.Info("Revoked the sessions of a user", "user_id", id)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeDeletedUserSessions handles the user.deleted event: the sessions of
+// a deleted user end at once rather than when its tokens expire
+func (h *AuthHandler) RevokeDeletedUserSessions(event bus.Event) error {
+	deleted, ok := event.Payload.(service.UserDeleted)
+	if !ok {
+		return fmt.Errorf("unexpected %s payload %T", event.Type, event.Payload)
+	}
+	return h.authService.RevokeSessions(context.Background(), deleted.User.ID)
+}
+
+// RegisterRoutes registers the auth routes. Revoking tokens requires an
+// access token.
+func (h *AuthHandler) RegisterRoutes(e *echo.Echo, basePath string, authenticator *auth.Authenticator) {
+	group := e.Group(basePath + "/auth")
+
+	group.POST("/login", h.Login)
+	group.POST("/refresh", h.Refresh)
+	group.POST("/logout", h.Logout)
+	group.POST("/revoke", h.RevokeToken, authenticator.Middleware())
+	group.DELETE("/sessions", h.RevokeOwnSessions, authenticator.Middleware())
+}
+
+// RegisterUserRoutes registers the auth routes of a user on the group of
+// the user routes. Ending the sessions of another user requires the
+// users:revoke_sessions permission.
+func (h *AuthHandler) RegisterUserRoutes(users *echo.Group) {
+	users.DELETE("/:id/sessions", h.RevokeUserSessions, rbac.RequireSelfOr("id", PermissionRevokeSessions))
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"go-modular-boilerplate/internal/pkg/auth"
+	"go-modular-boilerplate/internal/pkg/bus"
+	"go-modular-boilerplate/internal/pkg/jwt"
+	"go-modular-boilerplate/internal/pkg/problem"
+	_validator "go-modular-boilerplate/internal/pkg/validator"
+	"go-modular-boilerplate/modules/users/domain/repository"
+	"go-modular-boilerplate/modules/users/domain/service"
+	"go-modular-boilerplate/modules/users/dto/response"
+	"go-modular-boilerplate/modules/users/internal/userstest"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestDeletedUserTokensAreRejected(t *testing.T) {
+	userstest.OpenDB(t)
+	userService, user := userstest.NewUserService(t, repository.NewUserRepositoryImpl())
+
+	a := userstest.NewAuth(userService)
+	issuer := a.Issuer
+	authenticator := auth.NewAuthenticator(auth.Config{JWT: issuer, Revocations: a.Revocations})
+
+	event := bus.New(bus.Config{})
+	authHandler := NewAuthHandler(userstest.Logger(t), a.Service)
+	event.SubscribeErrorFunc("user.deleted", authHandler.RevokeDeletedUserSessions)
+	userHandler := NewUserHandler(userstest.Logger(t), event, userService)
+
+	e := echo.New()
+	e.Validator = _validator.NewCustomValidator()
+	e.HTTPErrorHandler = problem.Handler(problem.Config{})
+	e.GET("/me", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, authenticator.Middleware())
+
+	token, err := issuer.GenerateToken(jwt.Claims{Subject: strconv.FormatUint(uint64(user.ID), 10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if status := get(); status != http.StatusOK {
+		t.Fatalf("expected the token to be accepted before the delete, got %d", status)
+	}
+
+	c := e.NewContext(httptest.NewRequest(http.MethodDelete, "/", nil), httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.FormatUint(uint64(user.ID), 10))
+	if err := userHandler.DeleteUser(c); err != nil {
+		t.Fatal(err)
+	}
+	event.Wait()
+
+	if status := get(); status != http.StatusUnauthorized {
+		t.Errorf("expected the token of a deleted user to be rejected, got %d", status)
+	}
+}
+
+func TestRevokeSessionsOfUnknownUser(t *testing.T) {
+	userstest.OpenDB(t)
+	userService, _ := userstest.NewUserService(t, repository.NewUserRepositoryImpl())
+	h := NewAuthHandler(userstest.Logger(t), userstest.NewAuth(userService).Service)
+
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodDelete, "/", nil), httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("404")
+	if err := h.RevokeUserSessions(c); !errors.Is(err, service.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestLoginRefreshLogout(t *testing.T) {
+	userstest.OpenDB(t)
+	userService, _ := userstest.NewUserService(t, repository.NewUserRepositoryImpl())
+	h := NewAuthHandler(userstest.Logger(t), userstest.NewAuth(userService).Service)
+
+	e := echo.New()
+	e.Validator = _validator.NewCustomValidator()
+	e.HTTPErrorHandler = problem.Handler(problem.Config{})
+	e.POST("/login", h.Login)
+	e.POST("/refresh", h.Refresh)
+	e.POST("/logout", h.Logout)
+
+	post := func(path, body string) (int, *response.TokenResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		tokens := new(response.TokenResponse)
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), tokens); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, tokens
+	}
+	refreshBody := func(token string) string {
+		return `{"refresh_token":"` + token + `"}`
+	}
+
+	if status, _ := post("/login", `{"email":"`+userstest.Email+`","password":"wrong-password"}`); status != http.StatusUnauthorized {
+		t.Errorf("expected a wrong password to be rejected with 401, got %d", status)
+	}
+	if status, _ := post("/login", `{"email":"nobody@example.com","password":"`+userstest.Password+`"}`); status != http.StatusUnauthorized {
+		t.Errorf("expected an unknown email to be rejected with 401, got %d", status)
+	}
+
+	status, login := post("/login", `{"email":"`+userstest.Email+`","password":"`+userstest.Password+`"}`)
+	if status != http.StatusOK || login.AccessToken == "" || login.RefreshToken == "" {
+		t.Fatalf("expected a successful login, got %d %+v", status, login)
+	}
+
+	status, refreshed := post("/refresh", refreshBody(login.RefreshToken))
+	if status != http.StatusOK || refreshed.RefreshToken == "" || refreshed.RefreshToken == login.RefreshToken {
+		t.Fatalf("expected the refresh token to be rotated, got %d %+v", status, refreshed)
+	}
+	if status, _ := post("/refresh", refreshBody(login.RefreshToken)); status != http.StatusUnauthorized {
+		t.Errorf("expected the rotated refresh token to be rejected, got %d", status)
+	}
+
+	// The reuse above revoked the session, so log out of a new one
+	status, login = post("/login", `{"email":"`+userstest.Email+`","password":"`+userstest.Password+`"}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected a successful login, got %d", status)
+	}
+	if status, _ := post("/logout", refreshBody(login.RefreshToken)); status != http.StatusNoContent {
+		t.Fatalf("expected the logout to succeed, got %d", status)
+	}
+	if status, _ := post("/refresh", refreshBody(login.RefreshToken)); status != http.StatusUnauthorized {
+		t.Errorf("expected the refresh token to be rejected after a logout, got %d", status)
+	}
+}
@@ -3,19 +3,45 @@
 package handler
 
 import (
-	"fmt"
+	"errors"
+	"go-modular-boilerplate/internal/pkg/auth"
 	"go-modular-boilerplate/internal/pkg/bus"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"go-modular-boilerplate/internal/pkg/etag"
 	"go-modular-boilerplate/internal/pkg/logger"
+	"go-modular-boilerplate/internal/pkg/mergepatch"
+	"go-modular-boilerplate/internal/pkg/query"
+	"go-modular-boilerplate/internal/pkg/rbac"
 	"go-modular-boilerplate/modules/users/domain/entity"
+	"go-modular-boilerplate/modules/users/domain/repository"
 	"go-modular-boilerplate/modules/users/domain/service"
 	"go-modular-boilerplate/modules/users/dto/request"
 	"go-modular-boilerplate/modules/users/dto/response"
+	"maps"
 	"net/http"
 	"strconv"
 
 	"github.com/labstack/echo"
 )
 
+// Permissions checked by the user routes
+const (
+	PermissionList           = "users:list"
+	PermissionListDeleted    = "users:list_deleted"
+	PermissionUpdate         = "users:update"
+	PermissionDelete         = "users:delete"
+	PermissionRestore        = "users:restore"
+	PermissionPurge          = "users:purge"
+	PermissionRevokeSessions = "users:revoke_sessions"
+)
+
+// Errors
+var (
+	ErrInvalidUserID         = errs.New(errs.Validation, "invalid_user_id", "invalid user ID")
+	ErrInvalidIncludeDeleted = errs.New(errs.Validation, "invalid_include_deleted", "include_deleted must be true or false")
+)
+
 // UserHandler handles HTTP requests for users
 type UserHandler struct {
 	userService *service.UserService
@@ -34,36 +60,66 @@ func NewUserHandler(log *logger.Logger, event *bus.EventBus, userService *servic
 
 // Event Bus Event user created
 func (h *UserHandler) Handle(event bus.Event) {
-	fmt.Printf("User created: %v", event.Payload)
+	h.log.Info("User created", "payload", event.Payload)
 }
 
-// GetAllUsers gets all users
+// GetAllUsers gets a page of users, filtered and sorted by the query string.
+// Deleted users are listed with include_deleted=true, which requires the
+// users:list_deleted permission.
 func (h *UserHandler) GetAllUsers(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	users, err := h.userService.GetAllUsers(ctx)
+	params := maps.Clone(c.QueryParams())
+	includeDeleted := false
+	if value := params.Get("include_deleted"); value != "" {
+		var err error
+		if includeDeleted, err = strconv.ParseBool(value); err != nil {
+			return ErrInvalidIncludeDeleted
+		}
+	}
+	params.Del("include_deleted")
+
+	if includeDeleted {
+		claims, _ := auth.FromEcho(c)
+		allowed, err := rbac.Default().Can(ctx, claims.Subject, PermissionListDeleted)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return rbac.ErrPermissionDenied
+		}
+	}
+
+	spec, err := repository.UserQuery.Parse(params)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 
-	return c.JSON(http.StatusOK, response.FromEntities(users))
+	users, err := h.userService.GetAllUsers(ctx, spec, includeDeleted)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, query.Map(users, response.FromEntity))
 }
 
-// GetUser gets a user by ID
+// GetUser gets a user by ID. The response has the ETag of the user's
+// version, a request whose If-None-Match has it gets 304 Not Modified.
 func (h *UserHandler) GetUser(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return ErrInvalidUserID
 	}
 
 	user, err := h.userService.GetUserByID(ctx, uint(id))
 	if err != nil {
-		if err == service.ErrUserNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
+	}
+
+	if etag.NotModified(c, etag.Version(user.CurrentVersion())) {
+		return c.NoContent(http.StatusNotModified)
 	}
 
 	return c.JSON(http.StatusOK, response.FromEntity(user))
@@ -75,20 +131,17 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 
 	req := new(request.CreateUserRequest)
 	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return err
 	}
 
 	if err := c.Validate(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return err
 	}
 
-	user := entity.NewUser(req.Name, req.Email, req.Password)
-	err := h.userService.CreateUser(ctx, user)
+	user := entity.NewUser(req.Name, req.Email)
+	err := h.userService.CreateUser(ctx, user, req.Password)
 	if err != nil {
-		if err == service.ErrEmailAlreadyUsed {
-			return c.JSON(http.StatusConflict, map[string]string{"error": "Email already in use"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
 	}
 
 	// event bus publish
@@ -97,73 +150,196 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 	return c.JSON(http.StatusCreated, response.FromEntity(user))
 }
 
-// UpdateUser updates a user
+// UpdateUser updates a user. A request with If-Match gets 412 Precondition
+// Failed unless it has the ETag of the user's current version.
 func (h *UserHandler) UpdateUser(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return ErrInvalidUserID
 	}
 
 	req := new(request.UpdateUserRequest)
 	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return err
 	}
 
 	if err := c.Validate(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return err
 	}
 
-	user, err := h.userService.GetUserByID(ctx, uint(id))
+	user, err := h.userService.GetUserForUpdate(ctx, uint(id))
 	if err != nil {
-		if err == service.ErrUserNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
+	}
+
+	if err := etag.CheckIfMatch(c, etag.Version(user.CurrentVersion())); err != nil {
+		return err
 	}
 
 	user.Name = req.Name
 	user.Email = req.Email
 	if req.Password != "" {
-		user.Password = req.Password
+		if err := h.userService.SetPassword(user, req.Password); err != nil {
+			return err
+		}
 	}
 
 	err = h.userService.UpdateUser(ctx, user)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return ifMatchFailed(c, err)
 	}
 
+	etag.Set(c, etag.Version(user.CurrentVersion()))
 	return c.JSON(http.StatusOK, response.FromEntity(user))
 }
 
-// DeleteUser deletes a user
+// PatchUser applies a JSON Merge Patch to a user. Only the fields in the
+// patch are validated and written; a user.updated event lists those whose
+// value changed. If-Match is checked as for UpdateUser.
+func (h *UserHandler) PatchUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return ErrInvalidUserID
+	}
+
+	req := new(request.PatchUserRequest)
+	members, err := mergepatch.Bind(c, req)
+	if err != nil {
+		return err
+	}
+
+	if err := members.NotNull("name", "email", "password"); err != nil {
+		return err
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	user, err := h.userService.GetUserForUpdate(ctx, uint(id))
+	if err != nil {
+		return err
+	}
+
+	if err := etag.CheckIfMatch(c, etag.Version(user.CurrentVersion())); err != nil {
+		return err
+	}
+
+	user, fields, err := h.userService.PatchUser(ctx, user, service.UserChanges{
+		Name:     req.Name,
+		Email:    req.Email,
+		Password: req.Password,
+	})
+	if err != nil {
+		return ifMatchFailed(c, err)
+	}
+
+	if len(fields) > 0 {
+		h.event.Publish(bus.Event{Type: "user.updated", Payload: service.UserUpdated{User: user, Fields: fields}})
+	}
+
+	etag.Set(c, etag.Version(user.CurrentVersion()))
+	return c.JSON(http.StatusOK, response.FromEntity(user))
+}
+
+// DeleteUser soft deletes a user and publishes a user.deleted event. If-Match
+// is checked as for UpdateUser.
 func (h *UserHandler) DeleteUser(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return ErrInvalidUserID
 	}
 
-	err = h.userService.DeleteUser(ctx, uint(id))
+	user, err := h.userService.GetUserForUpdate(ctx, uint(id))
 	if err != nil {
-		if err == service.ErrUserNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return err
+	}
+
+	if err := etag.CheckIfMatch(c, etag.Version(user.CurrentVersion())); err != nil {
+		return err
+	}
+
+	err = h.userService.DeleteUser(ctx, user)
+	if err != nil {
+		return ifMatchFailed(c, err)
+	}
+
+	h.event.Publish(bus.Event{Type: "user.deleted", Payload: service.UserDeleted{User: user}})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ifMatchFailed returns etag.ErrPreconditionFailed for a write that failed
+// with database.ErrStaleVersion on a request with If-Match: the version its
+// ETag matched was replaced by a concurrent write after the check
+func ifMatchFailed(c echo.Context, err error) error {
+	if errors.Is(err, database.ErrStaleVersion) && c.Request().Header.Get(etag.HeaderIfMatch) != "" {
+		return etag.ErrPreconditionFailed.Wrap(err)
+	}
+	return err
+}
+
+// RestoreUser restores a deleted user
+func (h *UserHandler) RestoreUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return ErrInvalidUserID
 	}
 
+	user, err := h.userService.RestoreUser(ctx, uint(id))
+	if err != nil {
+		return err
+	}
+
+	h.log.Info("Restored user", "user_id", user.ID)
+	etag.Set(c, etag.Version(user.CurrentVersion()))
+	return c.JSON(http.StatusOK, response.FromEntity(user))
+}
+
+// PurgeUser deletes a user for good, whether it is deleted or not, and
+// publishes a user.deleted event
+func (h *UserHandler) PurgeUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return ErrInvalidUserID
+	}
+
+	user, err := h.userService.PurgeUser(ctx, uint(id))
+	if err != nil {
+		return err
+	}
+
+	h.log.Info("Purged user", "user_id", user.ID)
+	h.event.Publish(bus.Event{Type: "user.deleted", Payload: service.UserDeleted{User: user, Purged: true}})
+
 	return c.NoContent(http.StatusNoContent)
 }
 
-// RegisterRoutes registers the user routes
-func (h *UserHandler) RegisterRoutes(e *echo.Echo, basePath string) {
-	group := e.Group(basePath + "/users")
+// RegisterRoutes registers the user routes and returns their group, on which
+// other handlers mount the routes of a user. Every route requires an access
+// token except sign-up; users can update themselves, updating other users,
+// listing, deleting, restoring and purging users require permissions.
+func (h *UserHandler) RegisterRoutes(e *echo.Echo, basePath string, authenticator *auth.Authenticator) *echo.Group {
+	group := e.Group(basePath+"/users", authenticator.Middleware())
 
-	group.GET("", h.GetAllUsers)
+	group.GET("", h.GetAllUsers, rbac.Require(PermissionList))
 	group.GET("/:id", h.GetUser)
-	group.POST("", h.CreateUser)
-	group.PUT("/:id", h.UpdateUser)
-	group.DELETE("/:id", h.DeleteUser)
+	authenticator.Public(group.POST("", h.CreateUser))
+	group.PUT("/:id", h.UpdateUser, rbac.RequireSelfOr("id", PermissionUpdate))
+	group.PATCH("/:id", h.PatchUser, rbac.RequireSelfOr("id", PermissionUpdate))
+	group.DELETE("/:id", h.DeleteUser, rbac.Require(PermissionDelete))
+	group.POST("/:id/restore", h.RestoreUser, rbac.Require(PermissionRestore))
+	group.POST("/:id/purge", h.PurgeUser, rbac.Require(PermissionPurge))
+
+	return group
 }
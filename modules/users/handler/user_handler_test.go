@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"go-modular-boilerplate/internal/pkg/bus"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/errs"
+	"go-modular-boilerplate/internal/pkg/etag"
+	_validator "go-modular-boilerplate/internal/pkg/validator"
+	"go-modular-boilerplate/modules/users/domain/entity"
+	"go-modular-boilerplate/modules/users/domain/repository"
+	"go-modular-boilerplate/modules/users/domain/service"
+	"go-modular-boilerplate/modules/users/internal/userstest"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+// racingRepository bumps the version of a user right before writing it, as
+// a concurrent request would between the handler's read and its write
+type racingRepository struct {
+	repository.UserRepositoryImpl
+}
+
+// bumpVersion writes a new version of a user behind the service's back
+func bumpVersion(id uint) error {
+	return database.DB.Exec("UPDATE users SET version = version + 1 WHERE id = ?", id).Error
+}
+
+func (r racingRepository) UpdateColumns(ctx context.Context, user *entity.User, columns map[string]interface{}) error {
+	if err := bumpVersion(user.ID); err != nil {
+		return err
+	}
+	return r.UserRepositoryImpl.UpdateColumns(ctx, user, columns)
+}
+
+func (r racingRepository) Update(ctx context.Context, user *entity.User) error {
+	return r.UpdateColumns(ctx, user, map[string]interface{}{"name": user.Name, "email": user.Email, "password": user.Password})
+}
+
+func (r racingRepository) Delete(ctx context.Context, user *entity.User) error {
+	if err := bumpVersion(user.ID); err != nil {
+		return err
+	}
+	return r.UserRepositoryImpl.Delete(ctx, user)
+}
+
+func TestWriteRacingIfMatchFailsPrecondition(t *testing.T) {
+	userstest.OpenDB(t)
+	userService, user := userstest.NewUserService(t, racingRepository{})
+
+	e := echo.New()
+	e.Validator = _validator.NewCustomValidator()
+	h := NewUserHandler(userstest.Logger(t), bus.New(bus.Config{}), userService)
+
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		handler echo.HandlerFunc
+	}{
+		{name: "put", method: http.MethodPut, body: `{"name":"Ada L","email":"ada@example.com"}`, handler: h.UpdateUser},
+		{name: "patch", method: http.MethodPatch, body: `{"name":"Ada B"}`, handler: h.PatchUser},
+		{name: "delete", method: http.MethodDelete, handler: h.DeleteUser},
+	}
+
+	for _, tt := range tests {
+		for _, ifMatch := range []bool{true, false} {
+			current, err := userService.GetUserForUpdate(context.Background(), user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if ifMatch {
+				req.Header.Set(etag.HeaderIfMatch, etag.Version(current.CurrentVersion()))
+			}
+			c := e.NewContext(req, httptest.NewRecorder())
+			c.SetParamNames("id")
+			c.SetParamValues(strconv.FormatUint(uint64(user.ID), 10))
+
+			want := http.StatusConflict
+			if ifMatch {
+				want = http.StatusPreconditionFailed
+			}
+			if err := tt.handler(c); errs.HTTPStatus(err) != want {
+				t.Errorf("%s with If-Match %v: expected %d, got %v", tt.name, ifMatch, want, err)
+			}
+		}
+	}
+}
+
+// racedEmailRepository finds no user by email, as when a concurrent request
+// takes the email between the service's check and its write
+type racedEmailRepository struct {
+	repository.UserRepositoryImpl
+}
+
+func (racedEmailRepository) FindByEmail(context.Context, string) (*entity.User, error) {
+	return nil, database.ErrNotFound
+}
+
+func TestEmailConflicts(t *testing.T) {
+	userstest.OpenDB(t)
+	userService, _ := userstest.NewUserService(t, repository.NewUserRepositoryImpl())
+	bob := entity.NewUser("Bob", "bob@example.com")
+	if err := userService.CreateUser(context.Background(), bob, "secret-password"); err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.Validator = _validator.NewCustomValidator()
+	h := NewUserHandler(userstest.Logger(t), bus.New(bus.Config{}), userService)
+
+	send := func(method, body string, handler echo.HandlerFunc, id uint) error {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.SetParamNames("id")
+		c.SetParamValues(strconv.FormatUint(uint64(id), 10))
+		return handler(c)
+	}
+
+	err := send(http.MethodPost, `{"name":"Ada","email":"ADA@Example.com","password":"secret-password"}`, h.CreateUser, 0)
+	if errs.HTTPStatus(err) != http.StatusConflict {
+		t.Errorf("expected a differently cased duplicate to be rejected with 409, got %v", err)
+	}
+
+	err = send(http.MethodPut, `{"name":"Bob","email":"Ada@example.com"}`, h.UpdateUser, bob.ID)
+	if errs.HTTPStatus(err) != http.StatusConflict {
+		t.Errorf("expected an update to a taken email to be rejected with 409, got %v", err)
+	}
+}
+
+func TestEmailConflictsRacingTheCheck(t *testing.T) {
+	userstest.OpenDB(t)
+	ctx := context.Background()
+	_, ada := userstest.NewUserService(t, repository.NewUserRepositoryImpl())
+
+	// The unique index ignores case even for a write that skipped the
+	// service's normalization
+	repo := repository.NewUserRepositoryImpl()
+	if err := repo.Create(ctx, &entity.User{Name: "Ada", Email: "ADA@EXAMPLE.COM"}); !errors.Is(err, repository.ERR_DUPLICATE_EMAIL) {
+		t.Errorf("expected ERR_DUPLICATE_EMAIL from the unique index, got %v", err)
+	}
+
+	hasher, err := userstest.Hasher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	userService := service.NewUserService(racedEmailRepository{}, hasher)
+
+	err = userService.CreateUser(ctx, entity.NewUser("Ada", "Ada@Example.com"), "secret-password")
+	if !errors.Is(err, service.ErrEmailAlreadyUsed) || !errors.Is(err, repository.ERR_DUPLICATE_EMAIL) {
+		t.Errorf("expected a racing create to be rejected by the unique index, got %v", err)
+	}
+
+	bob := entity.NewUser("Bob", "bob@example.com")
+	if err := userService.CreateUser(ctx, bob, "secret-password"); err != nil {
+		t.Fatal(err)
+	}
+	bob.Email = ada.Email
+	err = userService.UpdateUser(ctx, bob)
+	if !errors.Is(err, service.ErrEmailAlreadyUsed) || !errors.Is(err, repository.ERR_DUPLICATE_EMAIL) {
+		t.Errorf("expected a racing update to be rejected by the unique index, got %v", err)
+	}
+	if errs.HTTPStatus(err) != http.StatusConflict {
+		t.Errorf("expected 409, got %d", errs.HTTPStatus(err))
+	}
+}
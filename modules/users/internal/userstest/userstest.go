@@ -0,0 +1,100 @@
+package userstest
+
+import (
+	"context"
+	"go-modular-boilerplate/internal/pkg/auth"
+	"go-modular-boilerplate/internal/pkg/database"
+	"go-modular-boilerplate/internal/pkg/jwt"
+	"go-modular-boilerplate/internal/pkg/logger"
+	"go-modular-boilerplate/internal/pkg/password"
+	"go-modular-boilerplate/modules/users/domain/entity"
+	"go-modular-boilerplate/modules/users/domain/repository"
+	"go-modular-boilerplate/modules/users/domain/service"
+	"go-modular-boilerplate/modules/users/migrations"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// Credentials of the user created by NewUserService
+const (
+	Email    = "ada@example.com"
+	Password = "secret-password"
+)
+
+// OpenDB opens an in-memory database with the user module's tables as
+// database.DB, restoring the previous one when the test ends
+func OpenDB(t *testing.T) {
+	t.Helper()
+
+	model := &database.DBModel{Driver: "sqlite", Name: database.SQLITE_MEMORY}
+	db, err := model.OpenDB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	for _, m := range migrations.Migrations(Hasher) {
+		if err := m.Up(db); err != nil {
+			t.Fatalf("migration %s: %v", m.Name, err)
+		}
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+}
+
+// Hasher returns a cheap bcrypt hasher
+func Hasher() (password.Hasher, error) {
+	return password.New(password.Config{Algorithm: password.Bcrypt, Bcrypt: password.BcryptConfig{Cost: 10}})
+}
+
+// Logger returns a logger writing errors to a temporary file
+func Logger(t *testing.T) *logger.Logger {
+	t.Helper()
+	log, err := logger.NewLogger(logger.Config{Level: logger.ErrorLevel, OutputPath: filepath.Join(t.TempDir(), "test.log")}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log
+}
+
+// NewUserService returns a user service on repo and a user it created with
+// Email and Password
+func NewUserService(t *testing.T, repo repository.UserRepository) (*service.UserService, *entity.User) {
+	t.Helper()
+
+	hasher, err := Hasher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	userService := service.NewUserService(repo, hasher)
+	user := entity.NewUser("Ada", Email)
+	if err := userService.CreateUser(context.Background(), user, Password); err != nil {
+		t.Fatal(err)
+	}
+	return userService, user
+}
+
+// Auth is an auth service with the issuer and revocation store it uses
+type Auth struct {
+	Service     *service.AuthService
+	Issuer      *jwt.JWTImpl
+	Revocations auth.Revocations
+}
+
+// NewAuth returns an auth service for users, with one minute access tokens
+// and one hour refresh tokens
+func NewAuth(users *service.UserService) Auth {
+	issuer := &jwt.JWTImpl{SignatureKey: "secret", TTL: time.Minute}
+	revocations := auth.NewMemoryRevocations(cache.New(time.Minute, time.Minute), time.Minute)
+	return Auth{
+		Service:     service.NewAuthService(users, repository.NewRefreshTokenRepositoryImpl(), issuer, revocations, time.Minute, time.Hour),
+		Issuer:      issuer,
+		Revocations: revocations,
+	}
+}
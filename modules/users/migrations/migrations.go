@@ -0,0 +1,262 @@
+package migrations
+
+import (
+	"fmt"
+	"go-modular-boilerplate/internal/pkg/migration"
+	"go-modular-boilerplate/internal/pkg/password"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migrations returns the user module's migrations in order. Each migration
+// uses its own snapshot of the schema so later changes to the entities do
+// not alter what an old migration does. The password hasher is only built
+// when a migration needs it, its error fails that migration.
+func Migrations(hasher func() (password.Hasher, error)) []migration.Migration {
+	return []migration.Migration{
+		createUsers(),
+		hashPasswords(hasher),
+		createRefreshTokens(),
+		uniqueEmails(),
+		versionUsers(),
+		softDeleteUsers(),
+		caseInsensitiveEmails(),
+	}
+}
+
+// userV1 is the users table as created by the first migration
+type userV1 struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"size:255"`
+	Email     string `gorm:"size:255"`
+	Password  string `gorm:"size:255"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (userV1) TableName() string {
+	return "users"
+}
+
+func createUsers() migration.Migration {
+	return migration.Migration{
+		Version: 1,
+		Name:    "create_users",
+		Up: func(tx *gorm.DB) error {
+			// Databases created before versioned migrations already have
+			// the table from AutoMigrate
+			if tx.Migrator().HasTable(&userV1{}) {
+				return nil
+			}
+			return tx.Migrator().CreateTable(&userV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&userV1{})
+		},
+	}
+}
+
+// hashPasswords replaces passwords stored in plain text by earlier versions
+// with hashes
+func hashPasswords(newHasher func() (password.Hasher, error)) migration.Migration {
+	return migration.Migration{
+		Version: 2,
+		Name:    "hash_passwords",
+		Up: func(tx *gorm.DB) error {
+			hasher, err := newHasher()
+			if err != nil {
+				return err
+			}
+
+			var users []userV1
+			update := tx.Session(&gorm.Session{NewDB: true})
+			return tx.Select("id", "password").FindInBatches(&users, 100, func(*gorm.DB, int) error {
+				for _, user := range users {
+					if password.IsHash(user.Password) {
+						continue
+					}
+					hash, err := hasher.Hash(user.Password)
+					if err != nil {
+						return err
+					}
+					if err := update.Model(&userV1{}).Where("id = ?", user.ID).Update("password", hash).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+		},
+		// The plain text passwords cannot be restored; the hashes remain
+		// valid for the previous version
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	}
+}
+
+// refreshTokenV1 is the refresh_tokens table as created by the third
+// migration
+type refreshTokenV1 struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"not null;index"`
+	FamilyID   string `gorm:"size:64;not null;index"`
+	TokenHash  string `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy *uint
+	CreatedAt  time.Time
+}
+
+func (refreshTokenV1) TableName() string {
+	return "refresh_tokens"
+}
+
+func createRefreshTokens() migration.Migration {
+	return migration.Migration{
+		Version: 3,
+		Name:    "create_refresh_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&refreshTokenV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&refreshTokenV1{})
+		},
+	}
+}
+
+// userV4 is the users table with the unique email index added by the
+// fourth migration
+type userV4 struct {
+	ID    uint   `gorm:"primaryKey"`
+	Email string `gorm:"size:255;uniqueIndex:idx_users_email"`
+}
+
+func (userV4) TableName() string {
+	return "users"
+}
+
+// uniqueEmails stores the emails in lower case and makes them unique.
+// It fails without changing anything if two users only differ by the case
+// of their email; those accounts have to be merged by hand first.
+func uniqueEmails() migration.Migration {
+	return migration.Migration{
+		Version: 4,
+		Name:    "unique_user_emails",
+		Up: func(tx *gorm.DB) error {
+			var duplicates []string
+			err := tx.Model(&userV4{}).
+				Select("LOWER(TRIM(email))").
+				Group("LOWER(TRIM(email))").
+				Having("COUNT(*) > 1").
+				Pluck("LOWER(TRIM(email))", &duplicates).Error
+			if err != nil {
+				return err
+			}
+			if len(duplicates) > 0 {
+				return fmt.Errorf("%d emails are used by more than one user, e.g. %s", len(duplicates), duplicates[0])
+			}
+
+			if err := tx.Exec("UPDATE users SET email = LOWER(TRIM(email))").Error; err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&userV4{}, "idx_users_email")
+		},
+		// Emails stay in lower case
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropIndex(&userV4{}, "idx_users_email")
+		},
+	}
+}
+
+// userV5 is the users table with the version column added by the fifth
+// migration
+type userV5 struct {
+	ID      uint `gorm:"primaryKey"`
+	Version uint `gorm:"not null;default:1"`
+}
+
+func (userV5) TableName() string {
+	return "users"
+}
+
+// versionUsers adds the version column used for optimistic concurrency
+// control, existing users start at version 1
+func versionUsers() migration.Migration {
+	return migration.Migration{
+		Version: 5,
+		Name:    "version_users",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&userV5{}, "Version")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&userV5{}, "Version")
+		},
+	}
+}
+
+// userV6 is the users table with the deleted_at column added by the sixth
+// migration
+type userV6 struct {
+	ID        uint           `gorm:"primaryKey"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (userV6) TableName() string {
+	return "users"
+}
+
+// softDeleteUsers adds the deleted_at column of soft deleted users. Rolling
+// it back purges them.
+func softDeleteUsers() migration.Migration {
+	return migration.Migration{
+		Version: 6,
+		Name:    "soft_delete_users",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&userV6{}, "DeletedAt"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&userV6{}, "DeletedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM users WHERE deleted_at IS NOT NULL").Error; err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&userV6{}, "DeletedAt"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&userV6{}, "DeletedAt")
+		},
+	}
+}
+
+// caseInsensitiveEmails makes the unique email index ignore case, so that a
+// write bypassing the user service's normalization cannot reuse an email in
+// another case. SQLite and PostgreSQL index LOWER(email); MySQL compares
+// the column with a case-insensitive collation instead, which its unique
+// index then follows.
+func caseInsensitiveEmails() migration.Migration {
+	return migration.Migration{
+		Version: 7,
+		Name:    "case_insensitive_user_emails",
+		Up: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() == "mysql" {
+				return tx.Exec("ALTER TABLE users MODIFY email VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci").Error
+			}
+			if err := tx.Migrator().DropIndex(&userV4{}, "idx_users_email"); err != nil {
+				return err
+			}
+			return tx.Exec("CREATE UNIQUE INDEX idx_users_email_lower ON users (LOWER(email))").Error
+		},
+		// MySQL keeps the collation, it was the default of most databases
+		Down: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() == "mysql" {
+				return nil
+			}
+			if err := tx.Migrator().DropIndex(&userV4{}, "idx_users_email_lower"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&userV4{}, "idx_users_email")
+		},
+	}
+}
@@ -1,12 +1,21 @@
 package user
 
 import (
+	"context"
+	"errors"
+	"go-modular-boilerplate/internal/pkg/auth"
 	"go-modular-boilerplate/internal/pkg/bus"
+	"go-modular-boilerplate/internal/pkg/config"
+	"go-modular-boilerplate/internal/pkg/jwt"
 	"go-modular-boilerplate/internal/pkg/logger"
-	"go-modular-boilerplate/modules/users/domain/entity"
+	"go-modular-boilerplate/internal/pkg/migration"
+	"go-modular-boilerplate/internal/pkg/password"
+	"go-modular-boilerplate/internal/pkg/rbac"
 	"go-modular-boilerplate/modules/users/domain/repository"
 	"go-modular-boilerplate/modules/users/domain/service"
 	"go-modular-boilerplate/modules/users/handler"
+	"go-modular-boilerplate/modules/users/migrations"
+	"time"
 
 	"github.com/labstack/echo"
 	"gorm.io/gorm"
@@ -18,7 +27,16 @@ type Module struct {
 	logger      *logger.Logger
 	userService *service.UserService
 	userHandler *handler.UserHandler
+	authService *service.AuthService
+	authHandler *handler.AuthHandler
 	event       *bus.EventBus
+	config      Config
+	hasher      password.Hasher
+
+	// stopPurge stops the purge of deleted users, purgeDone is closed once
+	// it has stopped
+	stopPurge context.CancelFunc
+	purgeDone chan struct{}
 }
 
 // Name returns the name of the module
@@ -34,21 +52,30 @@ func (m *Module) Initialize(db *gorm.DB, log *logger.Logger, event *bus.EventBus
 
 	m.logger.Info("Initializing user module")
 
+	hasher, err := m.passwordHasher()
+	if err != nil {
+		return err
+	}
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepositoryImpl()
+	refreshTokenRepo := repository.NewRefreshTokenRepositoryImpl()
 	m.logger.Debug("User repository initialized")
 
 	// Initialize services
-	m.userService = service.NewUserService(userRepo)
+	m.userService = service.NewUserService(userRepo, hasher)
+	m.authService = service.NewAuthService(m.userService, refreshTokenRepo, jwt.Default(), auth.Default().Revocations(), config.Get().JWT.AccessTTL, m.config.Auth.RefreshTTL)
 	m.logger.Debug("User service initialized")
 
 	// Initialize handlers
 	m.userHandler = handler.NewUserHandler(m.logger, m.event, m.userService)
+	m.authHandler = handler.NewAuthHandler(m.logger, m.authService)
 	m.logger.Debug("User handler initialized")
 
 	// register event listeners
 	m.logger.Info("Registering user module event listeners")
 	m.event.SubscribeFunc("user.created", m.userHandler.Handle)
+	m.event.SubscribeErrorFunc("user.deleted", m.authHandler.RevokeDeletedUserSessions)
 
 	m.logger.Info("User module initialized successfully")
 	return nil
@@ -56,15 +83,106 @@ func (m *Module) Initialize(db *gorm.DB, log *logger.Logger, event *bus.EventBus
 
 // RegisterRoutes registers the module's routes
 func (m *Module) RegisterRoutes(e *echo.Echo, basePath string) {
-	m.logger.Info("Registering user routes at %s/users", basePath)
-	m.userHandler.RegisterRoutes(e, basePath)
+	m.logger.Info("Registering user routes", "path", basePath+"/users")
+	users := m.userHandler.RegisterRoutes(e, basePath, auth.Default())
+	m.logger.Info("Registering auth routes", "path", basePath+"/auth")
+	m.authHandler.RegisterRoutes(e, basePath, auth.Default())
+	m.authHandler.RegisterUserRoutes(users)
 	m.logger.Debug("User routes registered successfully")
 }
 
+// Start starts purging the users deleted for longer than the retention of
+// [modules.user.deletion], until Stop cancels the purge
+func (m *Module) Start(ctx context.Context) error {
+	if m.config.Deletion.Retention == 0 {
+		m.logger.Info("Deleted users are kept until they are purged by hand")
+		return nil
+	}
+
+	purgeCtx, cancel := context.WithCancel(ctx)
+	m.stopPurge = cancel
+	m.purgeDone = make(chan struct{})
+	go m.purgeDeletedUsers(purgeCtx)
+	return nil
+}
+
+// Stop stops purging deleted users, waiting for a running purge to end
+func (m *Module) Stop(ctx context.Context) error {
+	if m.stopPurge == nil {
+		return nil
+	}
+	m.stopPurge()
+
+	select {
+	case <-m.purgeDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// purgeDeletedUsers purges the users deleted for longer than the retention
+// every purge interval until ctx is cancelled
+func (m *Module) purgeDeletedUsers(ctx context.Context) {
+	defer close(m.purgeDone)
+
+	ticker := time.NewTicker(m.config.Deletion.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().Add(-m.config.Deletion.Retention)
+		users, err := m.userService.PurgeDeletedUsers(ctx, before)
+		for _, user := range users {
+			m.event.Publish(bus.Event{Type: "user.deleted", Payload: service.UserDeleted{User: user, Purged: true}})
+		}
+		if len(users) > 0 {
+			m.logger.Info("Purged deleted users", "count", len(users), "before", before.Format(time.RFC3339))
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			m.logger.Error("Failed to purge deleted users", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // Migrations returns the module's migrations
-func (m *Module) Migrations() error {
-	m.logger.Info("Registering user module migrations")
-	return m.db.AutoMigrate(&entity.User{})
+func (m *Module) Migrations() []migration.Migration {
+	return migrations.Migrations(m.passwordHasher)
+}
+
+// Permissions returns the permissions checked by the module's routes
+func (m *Module) Permissions() []rbac.Permission {
+	return []rbac.Permission{
+		{Name: handler.PermissionList, Description: "List every user"},
+		{Name: handler.PermissionListDeleted, Description: "List deleted users"},
+		{Name: handler.PermissionUpdate, Description: "Update any user"},
+		{Name: handler.PermissionDelete, Description: "Delete any user"},
+		{Name: handler.PermissionRestore, Description: "Restore a deleted user"},
+		{Name: handler.PermissionPurge, Description: "Delete any user for good"},
+		{Name: handler.PermissionRevokeSessions, Description: "Log any user out everywhere"},
+	}
+}
+
+// Config returns the module's configuration, decoded from [modules.user]
+func (m *Module) Config() interface{} {
+	return &m.config
+}
+
+// passwordHasher returns the hasher configured in [modules.user.password]
+func (m *Module) passwordHasher() (password.Hasher, error) {
+	if m.hasher == nil {
+		hasher, err := password.New(m.config.Password)
+		if err != nil {
+			return nil, err
+		}
+		m.hasher = hasher
+	}
+	return m.hasher, nil
 }
 
 // Logger returns the module's logger
@@ -74,5 +192,7 @@ func (m *Module) Logger() *logger.Logger {
 
 // NewModule creates a new user module
 func NewModule() *Module {
-	return &Module{}
+	return &Module{
+		config: DefaultConfig(),
+	}
 }
@@ -3,6 +3,16 @@
 # Make sure the script exits on any error
 set -e
 
+# Generate the secrets mounted by docker-compose.yml on first run
+mkdir -p secrets
+for secret in db_password jwt_signature_key; do
+    if [ ! -f "secrets/$secret" ]; then
+        echo "Generating secrets/$secret..."
+        head -c 24 /dev/urandom | base64 | tr -d '/+=' > "secrets/$secret"
+        chmod 600 "secrets/$secret"
+    fi
+done
+
 echo "Starting the application with Docker Compose..."
 
 # Build and start the containers